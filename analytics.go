@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+// maxTopWords is the largest `n` /analytics/top may ask for.
+const maxTopWords = 1000
+
+// WordCount is how often a word occurred in the articles fetched for its language.
+type WordCount struct {
+	Word  string `json:"word"`
+	Count int64  `json:"count"`
+}
+
+// TopWordsResponse is returned by /analytics/top.
+type TopWordsResponse struct {
+	Language string      `json:"language"`
+	Words    []WordCount `json:"words"`
+}
+
+func initAnalyticsTable() error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS word_counts (language TEXT,word TEXT,count INTEGER NOT NULL DEFAULT 0,PRIMARY KEY(language, word))`)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`CREATE INDEX IF NOT EXISTS word_counts_language_count ON word_counts(language, count)`)
+	return err
+}
+
+// recordWordCounts adds the occurrences of every word of the articles to the language's
+// running totals. All words are counted, before any pick's filters, and across namespaces,
+// since the articles are shared.
+func recordWordCounts(ctx context.Context, language string, articles []*Article) error {
+	counts := make(map[string]int64)
+	for _, article := range articles {
+		if article == nil {
+			continue
+		}
+		for _, word := range WordsFromParagraphs(article.Paragraphs, language) {
+			counts[word]++
+		}
+	}
+	if len(counts) == 0 {
+		return nil
+	}
+
+	return retryBusy(ctx, func() error { return storeWordCounts(ctx, language, counts) })
+}
+
+// storeWordCounts adds the counts to the language's word_counts in one transaction.
+func storeWordCounts(ctx context.Context, language string, counts map[string]int64) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`INSERT INTO word_counts(language,word,count) VALUES (?,?,?)
+		ON CONFLICT(language, word) DO UPDATE SET count=count+excluded.count`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for word, count := range counts {
+		if _, err := stmt.Exec(language, word, count); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// getTopWords returns the n most frequent words of the language, most frequent first.
+func getTopWords(tx dbtx, language string, n int) ([]WordCount, error) {
+	rows, err := tx.Query(`SELECT word, count FROM word_counts WHERE language=? ORDER BY count DESC, word LIMIT ?`, language, n)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	words := []WordCount{}
+	for rows.Next() {
+		var word WordCount
+		if err := rows.Scan(&word.Word, &word.Count); err != nil {
+			return nil, err
+		}
+		words = append(words, word)
+	}
+
+	return words, rows.Err()
+}
+
+// topWordsHandler serves the frequency list that emerges from the articles fetched for picks.
+func topWordsHandler(w http.ResponseWriter, r *http.Request) {
+	language := requestLanguage(r)
+
+	n := 100
+	if value := r.URL.Query().Get("n"); value != "" {
+		var err error
+		n, err = strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxTopWords {
+			writeError(w, invalidParameter("n", "n must be between 1 and %d", maxTopWords))
+			return
+		}
+	}
+
+	words, err := getTopWords(db, language, n)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(TopWordsResponse{Language: language, Words: words})
+}
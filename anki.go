@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// maxAnkiDefinitions is how many words an export looks up on Wiktionary. Each lookup is one
+// or two upstream requests, so a long history would otherwise hold the export open for
+// minutes; the older words are exported without a definition.
+const maxAnkiDefinitions = 200
+
+// ankiExportHandler serves the used-word history of a language as a tab-separated file that
+// Anki imports as notes: the word on the front, its definition on the back when
+// `definitions=true`, and a link to the source article. `limit` exports only the most recent
+// words.
+func ankiExportHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	language := requestLanguage(r)
+	withDefinitions, _ := strconv.ParseBool(query.Get("definitions"))
+
+	limit := -1
+	if value := query.Get("limit"); value != "" {
+		var err error
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 {
+			writeError(w, invalidParameter("limit", "limit must be a positive integer"))
+			return
+		}
+	}
+
+	entries, err := getHistory(db, requestNamespace(r.Context()), language, requestUser(r.Context()), time.Time{}, time.Time{}, limit)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	definitions := make(map[string]string)
+	if withDefinitions {
+		var mu sync.Mutex
+		group, ctx := errgroup.WithContext(r.Context())
+		group.SetLimit(articleParallelism)
+		for _, word := range ankiLookupWords(entries) {
+			group.Go(func() error {
+				definition, err := lookupDefinition(ctx, word, language)
+				if err != nil {
+					// Cards without a definition are still worth exporting.
+					return nil
+				}
+				mu.Lock()
+				definitions[word] = definition
+				mu.Unlock()
+				return nil
+			})
+		}
+		group.Wait()
+	}
+
+	w.Header().Set("Content-Type", "text/tab-separated-values; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="words-%s.txt"`, language))
+
+	fmt.Fprint(w, "#separator:tab\n#html:true\n#columns:Front\tBack\tSource\tTags\n")
+	for _, entry := range entries {
+		source := ""
+		if entry.ArticleURL != "" {
+			title := entry.ArticleTitle
+			if title == "" {
+				title = entry.ArticleURL
+			}
+			source = fmt.Sprintf(`<a href="%s">%s</a>`, html.EscapeString(entry.ArticleURL), html.EscapeString(title))
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n",
+			ankiField(entry.Word),
+			ankiField(definitions[entry.Word]),
+			ankiField(source),
+			"wikipedia-word-picker "+ankiField(language))
+	}
+}
+
+// ankiLookupWords returns the distinct words of the entries to look up, most recent first, up
+// to maxAnkiDefinitions.
+func ankiLookupWords(entries []HistoryEntry) []string {
+	seen := make(map[string]bool)
+	var words []string
+	for _, entry := range entries {
+		if len(words) == maxAnkiDefinitions {
+			break
+		}
+		if !seen[entry.Word] {
+			seen[entry.Word] = true
+			words = append(words, entry.Word)
+		}
+	}
+	return words
+}
+
+// ankiField keeps a value on one line and inside its column.
+func ankiField(value string) string {
+	return strings.NewReplacer("\t", " ", "\r", " ", "\n", " ").Replace(value)
+}
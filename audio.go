@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// wiktionaryAPIURL returns the action API of a language's Wiktionary edition, whose entries
+// embed pronunciation recordings hosted on Wikimedia Commons.
+func wiktionaryAPIURL(language string, query url.Values) string {
+	u := url.URL{Scheme: "https", Host: language + ".wiktionary.org", Path: "/w/api.php", RawQuery: query.Encode()}
+	return u.String()
+}
+
+// audioExtensions are the file types Commons stores recordings in.
+var audioExtensions = map[string]bool{".ogg": true, ".oga": true, ".opus": true, ".wav": true, ".mp3": true, ".flac": true}
+
+// audioResponse is the part of an action=query&generator=images&prop=imageinfo response we read.
+type audioResponse struct {
+	Query struct {
+		Pages []struct {
+			Title     string `json:"title"`
+			ImageInfo []struct {
+				URL string `json:"url"`
+			} `json:"imageinfo"`
+		} `json:"pages"`
+	} `json:"query"`
+}
+
+// lookupAudio returns the pronunciation recordings of each word on the language's Wiktionary.
+// Words without recordings, or whose lookup fails, are left out, and so are all of them for a
+// language that isn't known.
+func lookupAudio(ctx context.Context, words []string, language string) map[string][]string {
+	var mu sync.Mutex
+	audio := make(map[string][]string)
+	if !knownLanguage(language) {
+		return audio
+	}
+
+	group, ctx := errgroup.WithContext(ctx)
+	group.SetLimit(articleParallelism)
+	for _, word := range words {
+		group.Go(func() error {
+			urls, err := lookupWordAudio(ctx, word, language)
+			if err != nil || len(urls) == 0 {
+				// Recordings are a nice extra; the pick stands without them.
+				return nil
+			}
+			mu.Lock()
+			audio[word] = urls
+			mu.Unlock()
+			return nil
+		})
+	}
+	group.Wait()
+
+	return audio
+}
+
+// lookupWordAudio returns the recordings on the Wiktionary entry of the word, trying the
+// capitalised form too like lookupDefinition.
+func lookupWordAudio(ctx context.Context, word string, language string) ([]string, error) {
+	variants := []string{word}
+	if first, size := utf8.DecodeRuneInString(word); unicode.IsLower(first) {
+		variants = append(variants, string(unicode.ToUpper(first))+word[size:])
+	}
+
+	for _, variant := range variants {
+		urls, err := fetchAudio(ctx, variant, language)
+		if err != nil || len(urls) > 0 {
+			return urls, err
+		}
+	}
+
+	return nil, nil
+}
+
+func fetchAudio(ctx context.Context, title string, language string) ([]string, error) {
+	release, err := acquireFetchSlot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	query := url.Values{
+		"action":        {"query"},
+		"format":        {"json"},
+		"formatversion": {"2"},
+		"titles":        {title},
+		"generator":     {"images"},
+		"gimlimit":      {"50"},
+		"prop":          {"imageinfo"},
+		"iiprop":        {"url"},
+		"maxlag":        {maxLag},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, wiktionaryAPIURL(language, query), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := upstreamClient.Do(req)
+	if err != nil {
+		return nil, upstreamError(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, upstreamError(fmt.Errorf("wiktionary returned %s", resp.Status))
+	}
+
+	var body audioResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, upstreamError(fmt.Errorf("failed to decode audio files: %w", err))
+	}
+
+	var urls []string
+	for _, page := range body.Query.Pages {
+		if !audioExtensions[strings.ToLower(path.Ext(page.Title))] {
+			continue
+		}
+		for _, info := range page.ImageInfo {
+			urls = append(urls, info.URL)
+		}
+	}
+
+	return urls, nil
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// AuditEntry records one pick served to a client.
+type AuditEntry struct {
+	ID       int64     `json:"id"`
+	At       time.Time `json:"at"`
+	Client   string    `json:"client"`
+	Key      string    `json:"key,omitempty"`
+	User     string    `json:"user,omitempty"`
+	Language string    `json:"language"`
+	Count    int       `json:"count"`
+	Articles []string  `json:"articles"`
+	Words    []string  `json:"words"`
+	Cached   bool      `json:"cached"`
+}
+
+// AuditResponse is returned by /admin/audit.
+type AuditResponse struct {
+	Entries []AuditEntry `json:"entries"`
+}
+
+// initAuditTable creates the audit log. Triggers reject updates and deletes, so entries
+// can only be added.
+func initAuditTable() error {
+	for _, statement := range []string{
+		`CREATE TABLE IF NOT EXISTS audit_log (id INTEGER PRIMARY KEY AUTOINCREMENT,at DATETIME,client TEXT,api_key TEXT,user TEXT,language TEXT,count INTEGER,articles TEXT,words TEXT,cached INTEGER)`,
+		`CREATE INDEX IF NOT EXISTS audit_log_at ON audit_log(at)`,
+		`CREATE TRIGGER IF NOT EXISTS audit_log_no_update BEFORE UPDATE ON audit_log BEGIN SELECT RAISE(ABORT, 'audit_log is append-only'); END`,
+		`CREATE TRIGGER IF NOT EXISTS audit_log_no_delete BEFORE DELETE ON audit_log BEGIN SELECT RAISE(ABORT, 'audit_log is append-only'); END`,
+	} {
+		if _, err := db.Exec(statement); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// recordAudit appends a served pick to the audit log.
+func recordAudit(tx dbtx, entry AuditEntry) error {
+	articles, err := json.Marshal(entry.Articles)
+	if err != nil {
+		return err
+	}
+	words, err := json.Marshal(entry.Words)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`INSERT INTO audit_log(at,client,api_key,user,language,count,articles,words,cached) VALUES (?,?,?,?,?,?,?,?,?)`,
+		entry.At.UTC(), entry.Client, entry.Key, entry.User, entry.Language, entry.Count, string(articles), string(words), entry.Cached)
+	return err
+}
+
+// getAudit returns the audit entries between from and to, newest first. Zero times leave
+// that end open; empty client, key and user match any.
+func getAudit(tx dbtx, from, to time.Time, client, key, user string, limit int) ([]AuditEntry, error) {
+	var fromArg, toArg any
+	if !from.IsZero() {
+		fromArg = from.UTC()
+	}
+	if !to.IsZero() {
+		toArg = to.UTC()
+	}
+
+	rows, err := tx.Query(`SELECT id, at, client, api_key, user, language, count, articles, words, cached FROM audit_log
+		WHERE (? IS NULL OR at >= ?) AND (? IS NULL OR at < ?)
+			AND (?='' OR client=?) AND (?='' OR api_key=?) AND (?='' OR user=?)
+		ORDER BY id DESC
+		LIMIT ?`, fromArg, fromArg, toArg, toArg, client, client, key, key, user, user, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []AuditEntry{}
+	for rows.Next() {
+		var entry AuditEntry
+		var articles, words string
+		var entryKey, entryUser sql.NullString
+		if err := rows.Scan(&entry.ID, &entry.At, &entry.Client, &entryKey, &entryUser, &entry.Language, &entry.Count, &articles, &words, &entry.Cached); err != nil {
+			return nil, err
+		}
+		entry.Key, entry.User = entryKey.String, entryUser.String
+		if err := json.Unmarshal([]byte(articles), &entry.Articles); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(words), &entry.Words); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
+
+// auditHandler lists audit entries, filtered by `from` and `to` (RFC 3339), `client`, `key`
+// and `user`. It is registered behind requireAdmin.
+func auditHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	var from, to time.Time
+	for name, value := range map[string]*time.Time{"from": &from, "to": &to} {
+		if text := query.Get(name); text != "" {
+			parsed, err := time.Parse(time.RFC3339, text)
+			if err != nil {
+				writeError(w, invalidParameter(name, "%s must be an RFC 3339 time", name))
+				return
+			}
+			*value = parsed
+		}
+	}
+
+	limit := 100
+	if value := query.Get("limit"); value != "" {
+		var err error
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 {
+			writeError(w, invalidParameter("limit", "limit must be a positive integer"))
+			return
+		}
+	}
+
+	entries, err := getAudit(db, from, to, query.Get("client"), query.Get("key"), query.Get("user"), limit)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(AuditResponse{Entries: entries})
+}
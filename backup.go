@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"modernc.org/sqlite"
+)
+
+// maxRestoreBytes bounds the size of an uploaded snapshot.
+const maxRestoreBytes = 1 << 30
+
+// sqliteHeader starts every SQLite database file.
+var sqliteHeader = []byte("SQLite format 3\x00")
+
+// RestoreResponse is returned by /admin/restore.
+type RestoreResponse struct {
+	Bytes int64 `json:"bytes"`
+}
+
+// backupConn is the part of a modernc.org/sqlite connection that runs the online backup API.
+type backupConn interface {
+	NewBackup(dstURI string) (*sqlite.Backup, error)
+	NewRestore(srcURI string) (*sqlite.Backup, error)
+}
+
+// copyDatabase copies the live database to the file at path, or the file into the live
+// database when restore is set, using SQLite's online backup API. Picks keep being served
+// while it runs: a backup sees a consistent snapshot, and a restore replaces the contents in
+// one step that concurrent picks wait for.
+func copyDatabase(ctx context.Context, path string, restore bool) error {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return conn.Raw(func(driverConn any) error {
+		sqliteConn, ok := driverConn.(backupConn)
+		if !ok {
+			return fmt.Errorf("the database driver doesn't support online backups")
+		}
+
+		start := sqliteConn.NewBackup
+		if restore {
+			start = sqliteConn.NewRestore
+		}
+		backup, err := start(path)
+		if err != nil {
+			return err
+		}
+		if _, err := backup.Step(-1); err != nil {
+			backup.Finish()
+			return err
+		}
+
+		return backup.Finish()
+	})
+}
+
+// backupHandler downloads a snapshot of the database.
+func backupHandler(w http.ResponseWriter, r *http.Request) {
+	file, err := os.CreateTemp("", "words-backup-*.db")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+
+	if err := copyDatabase(r.Context(), file.Name(), false); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	snapshot, err := os.Open(file.Name())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer snapshot.Close()
+
+	w.Header().Set("Content-Type", "application/vnd.sqlite3")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="words-%s.db"`, time.Now().UTC().Format("20060102T150405Z")))
+	if info, err := snapshot.Stat(); err == nil {
+		w.Header().Set("Content-Length", fmt.Sprint(info.Size()))
+	}
+	io.Copy(w, snapshot)
+}
+
+// restoreHandler replaces the database with an uploaded snapshot, upgrading it to the current
+// schema. Cached pick responses are dropped, since they may hold words the snapshot hasn't used.
+func restoreHandler(w http.ResponseWriter, r *http.Request) {
+	file, err := os.CreateTemp("", "words-restore-*.db")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer os.Remove(file.Name())
+
+	size, err := io.Copy(file, http.MaxBytesReader(w, r.Body, maxRestoreBytes))
+	file.Close()
+	if err != nil {
+		writeError(w, invalidParameter("body", "failed to read snapshot: %v", err))
+		return
+	}
+	if err := checkSnapshot(file.Name()); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := copyDatabase(r.Context(), file.Name(), true); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := initSchema(); err != nil {
+		writeError(w, err)
+		return
+	}
+	responseCache.clear()
+	slog.InfoContext(r.Context(), "Restored database", "bytes", size)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(RestoreResponse{Bytes: size})
+}
+
+// checkSnapshot verifies that the file is an intact SQLite database before it replaces the
+// live one.
+func checkSnapshot(path string) error {
+	header := make([]byte, len(sqliteHeader))
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	_, err = io.ReadFull(file, header)
+	file.Close()
+	if err != nil || !bytes.Equal(header, sqliteHeader) {
+		return invalidParameter("body", "the snapshot is not an SQLite database")
+	}
+
+	snapshot, err := sql.Open("sqlite", "file:"+path+"?mode=ro")
+	if err != nil {
+		return err
+	}
+	defer snapshot.Close()
+
+	var result string
+	if err := snapshot.QueryRow("PRAGMA integrity_check").Scan(&result); err != nil {
+		return invalidParameter("body", "the snapshot can't be read: %v", err)
+	}
+	if result != "ok" {
+		return invalidParameter("body", "the snapshot is corrupt: %s", result)
+	}
+
+	return nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// breakerThreshold is how many upstream failures in a row open a circuit breaker. Zero
+// disables the breakers.
+var breakerThreshold = 5
+
+// breakerCooldown is how long an open breaker rejects fetches before letting one through to
+// test whether the upstream has recovered.
+var breakerCooldown = 30 * time.Second
+
+// circuitBreaker stops fetches from an upstream that keeps failing, so requests fail fast
+// with a 503 instead of each waiting for its own timeout.
+type circuitBreaker struct {
+	mu       sync.Mutex
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+var (
+	breakersMu sync.Mutex
+	breakers   = map[string]*circuitBreaker{}
+)
+
+// upstreamBreaker returns the breaker of an upstream, such as "wikipedia:en".
+func upstreamBreaker(upstream string) *circuitBreaker {
+	breakersMu.Lock()
+	defer breakersMu.Unlock()
+
+	breaker, found := breakers[upstream]
+	if !found {
+		breaker = &circuitBreaker{}
+		breakers[upstream] = breaker
+	}
+
+	return breaker
+}
+
+// allow returns an error if the breaker is open. Once the cooldown has passed, a single
+// fetch is let through; its result closes the breaker or keeps it open for another cooldown.
+func (b *circuitBreaker) allow(upstream string, now time.Time) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if breakerThreshold <= 0 || b.failures < breakerThreshold {
+		return nil
+	}
+
+	retryAfter := b.openedAt.Add(breakerCooldown).Sub(now)
+	if retryAfter <= 0 && !b.probing {
+		b.probing = true
+		return nil
+	}
+
+	return &apiError{
+		Status:  http.StatusServiceUnavailable,
+		Code:    CodeUpstreamUnavailable,
+		Message: fmt.Sprintf("%s is failing, fetches are paused", upstream),
+		Details: map[string]any{"retry_after_seconds": int(math.Ceil(max(retryAfter, 0).Seconds()))},
+	}
+}
+
+// record counts the outcome of a fetch that allow let through. Only upstream failures count
+// against the upstream; cancelled requests and our own errors don't.
+func (b *circuitBreaker) record(ctx context.Context, err error, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+
+	var apiErr *apiError
+	if ctx.Err() != nil || err == nil || !errors.As(err, &apiErr) || apiErr.Code != CodeUpstreamError && apiErr.Code != CodeUpstreamTimeout {
+		if err == nil {
+			b.failures = 0
+		}
+		return
+	}
+
+	b.failures++
+	if b.failures >= breakerThreshold {
+		b.openedAt = now
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// cacheTTL is how long an encoded pick response is reused for identical requests.
+// Zero disables the cache.
+var cacheTTL time.Duration
+
+// responseCache holds encoded pick responses keyed by their options.
+var responseCache = &pickCache{entries: make(map[string]cacheEntry)}
+
+// pickResult is an encoded pick response together with what the audit log records about it.
+type pickResult struct {
+	body     []byte
+	language string
+	words    []string
+	articles []string
+	cached   bool
+
+	// replayed is set on the stored response to a repeated Idempotency-Key.
+	replayed bool
+}
+
+type cacheEntry struct {
+	result  *pickResult
+	expires time.Time
+}
+
+type pickCache struct {
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+	flight  singleflight.Group
+}
+
+func (c *pickCache) get(key string, now time.Time) (*pickResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, found := c.entries[key]
+	if !found || now.After(entry.expires) {
+		return nil, false
+	}
+
+	cached := *entry.result
+	cached.cached = true
+	return &cached, true
+}
+
+// set stores the result and drops any entries that have expired.
+func (c *pickCache) set(key string, result *pickResult, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for k, entry := range c.entries {
+		if now.After(entry.expires) {
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = cacheEntry{result: result, expires: now.Add(cacheTTL)}
+}
+
+// clear drops every cached response.
+func (c *pickCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	clear(c.entries)
+}
+
+// cachedPick returns the encoded response for the options. When the cache is enabled,
+// identical requests within cacheTTL share one response, and identical requests arriving
+// together share a single pick.
+func cachedPick(ctx context.Context, opts pickOptions) (*pickResult, error) {
+	// Each pick with a cursor moves it on, so none may be answered from the cache. Debug
+	// and meta picks report their own timings.
+	if cacheTTL <= 0 || opts.Cursor != "" || opts.Debug || opts.Meta {
+		return encodePick(ctx, opts)
+	}
+
+	// Each user's picks are recorded in their own history, so users don't share responses.
+	key := opts.fingerprint() + "\x00" + requestUser(ctx)
+	if result, found := responseCache.get(key, time.Now()); found {
+		return result, nil
+	}
+
+	result, err, shared := responseCache.flight.Do(key, func() (any, error) {
+		// The pick is shared, so it must not be cancelled when the first caller goes away.
+		result, err := encodePick(context.WithoutCancel(ctx), opts)
+		if err != nil {
+			return nil, err
+		}
+		responseCache.set(key, result, time.Now())
+		return result, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if shared {
+		copied := *result.(*pickResult)
+		copied.cached = true
+		return &copied, nil
+	}
+
+	return result.(*pickResult), nil
+}
+
+func encodePick(ctx context.Context, opts pickOptions) (*pickResult, error) {
+	response, err := pick(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := json.Marshal(response)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pickResult{
+		body:     append(body, '\n'),
+		language: response.Language,
+		words:    response.Words,
+		articles: auditedArticles(response.Sources),
+	}, nil
+}
+
+// auditedArticles returns the URLs of the sources for the audit log.
+func auditedArticles(sources []Source) []string {
+	var articles []string
+	for _, source := range sources {
+		// Dump articles have no URL, only a title.
+		if source.URL != "" {
+			articles = append(articles, source.URL)
+		} else {
+			articles = append(articles, source.Title)
+		}
+	}
+
+	return articles
+}
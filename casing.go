@@ -0,0 +1,88 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+)
+
+// ToLowerLanguage lowercases s by the rules of the language. Turkish and Azerbaijani lowercase
+// I to dotless ı and İ to i, and Greek writes a sigma ending a word as ς. Other languages use
+// the plain Unicode mapping, which leaves ß as it is instead of folding it to ss.
+func ToLowerLanguage(s string, language string) string {
+	switch language {
+	case "tr", "az":
+		return strings.ToLowerSpecial(unicode.TurkishCase, s)
+	case "el":
+		return finalSigma(strings.ToLower(s))
+	}
+
+	return strings.ToLower(s)
+}
+
+// finalSigma replaces σ with ς at the end of words.
+func finalSigma(s string) string {
+	if !strings.ContainsRune(s, 'σ') {
+		return s
+	}
+
+	runes := []rune(s)
+	for i, r := range runes {
+		if r == 'σ' && i > 0 && unicode.IsLetter(runes[i-1]) && (i+1 == len(runes) || !unicode.IsLetter(runes[i+1])) {
+			runes[i] = 'ς'
+		}
+	}
+
+	return string(runes)
+}
+
+// originalCases maps the lowercased words or phrases of n words in the paragraphs to how they
+// were written. A lowercase spelling wins over a capitalised one, so words capitalised only
+// at the start of a sentence are returned in lowercase; names, German nouns and acronyms keep
+// their capitals.
+func originalCases(cases map[string]string, paragraphs []string, n int, language string) {
+	for _, original := range ngrams(paragraphs, n, KeepLetters) {
+		lower := ToLowerLanguage(original, language)
+		if _, found := cases[lower]; !found || original == lower {
+			cases[lower] = original
+		}
+	}
+}
+
+// restoreCase rewrites the picked words, and the maps keyed by them, in their original case.
+func (r *Response) restoreCase(cases map[string]string) {
+	original := func(word string) string {
+		if cased, found := cases[word]; found {
+			return cased
+		}
+		return word
+	}
+
+	for i, word := range r.Words {
+		r.Words[i] = original(word)
+	}
+	for _, section := range r.Sections {
+		for i, word := range section.Words {
+			section.Words[i] = original(word)
+		}
+	}
+	r.Contexts = rekey(r.Contexts, original)
+	r.Difficulty = rekey(r.Difficulty, original)
+	r.Scrabble = rekey(r.Scrabble, original)
+	r.Syllables = rekey(r.Syllables, original)
+	r.Occurrences = rekey(r.Occurrences, original)
+	r.Audio = rekey(r.Audio, original)
+	r.Categories = rekey(r.Categories, original)
+}
+
+func rekey[V any](m map[string]V, key func(string) string) map[string]V {
+	if m == nil {
+		return nil
+	}
+
+	rekeyed := make(map[string]V, len(m))
+	for k, v := range m {
+		rekeyed[key(k)] = v
+	}
+
+	return rekeyed
+}
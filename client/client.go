@@ -0,0 +1,562 @@
+// Package client is a Go client for the Wikipedia Word Picker API.
+//
+//	c := client.New("http://localhost:8080")
+//	resp, err := c.Pick(ctx, client.PickOptions{Language: "de", Count: 5})
+package client
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Client calls a Wikipedia Word Picker server.
+type Client struct {
+	// BaseURL is the server address, such as "http://localhost:8080".
+	BaseURL string
+
+	// APIKey is sent in the X-API-Key header when the server requires a key.
+	APIKey string
+
+	// Namespace is sent in the X-Namespace header, keeping the used words, word lists and
+	// stats of the client's app apart from others on the same server.
+	Namespace string
+
+	// HTTPClient sends the requests. It defaults to http.DefaultClient.
+	HTTPClient *http.Client
+
+	// MaxRetries is how many times a request is retried after a network error or a
+	// 429, 502, 503 or 504 response.
+	MaxRetries int
+
+	// RetryBackoff is the wait before the first retry. It doubles with each retry.
+	RetryBackoff time.Duration
+}
+
+// New returns a client for the server at baseURL that retries failed requests twice.
+func New(baseURL string) *Client {
+	return &Client{
+		BaseURL:      strings.TrimSuffix(baseURL, "/"),
+		HTTPClient:   http.DefaultClient,
+		MaxRetries:   2,
+		RetryBackoff: 500 * time.Millisecond,
+	}
+}
+
+// PickOptions are the parameters of a pick. Zero values leave the server default in place.
+type PickOptions struct {
+	Source    string
+	Project   string
+	Language  string
+	Fallback  []string
+	Count     int
+	Articles  int
+	NgramSize int
+
+	StartsWith string
+	EndsWith   string
+	Pattern    string
+	Crossword  string
+
+	// Safe and Unique are pointers so that false can be told apart from unset.
+	Safe   *bool
+	Unique *bool
+
+	Stats       bool
+	Context     bool
+	Excerpt     bool
+	Difficulty  bool
+	Scrabble    bool
+	Syllables   bool
+	Occurrences bool
+	Audio       bool
+	Categories  bool
+
+	// PreserveCase returns words as written in the article instead of lowercased.
+	PreserveCase bool
+
+	// ForeignScript keeps words written in another script than the language's.
+	ForeignScript bool
+
+	// AcrossLanguages, when set, overrides whether a word picked in any language counts as
+	// used in every language. Namespaces can default to it in the server's config.
+	AcrossLanguages *bool
+
+	// Script limits the words to those written in these Unicode scripts, such as "latin" or
+	// "cyrillic,latin", instead of the language's own.
+	Script string
+
+	// GroupBy set to "section" groups the words by the article section they came from.
+	GroupBy string
+
+	// Cursor set to "new" starts a cursor; the response's Cursor passed here on later picks
+	// returns words none of the earlier picks with it returned.
+	Cursor string
+
+	// Bias set to "rare" or "common" favours words by how uncommon or common they are.
+	Bias string
+
+	// PreferLength, a length like "7" or a range like "6-10", makes words of that many
+	// letters LengthWeight times as likely to be picked. A zero LengthWeight uses the server
+	// default.
+	PreferLength string
+	LengthWeight float64
+
+	// URL picks from this one page instead of random articles. The server must allow its host.
+	URL string
+
+	// Peek returns unused words without marking them as used, for previews.
+	Peek bool
+
+	// Debug returns Diagnostics of the extraction. Like Peek, it doesn't use up the words.
+	Debug bool
+
+	// Meta returns Meta, how long each stage of the pick took.
+	Meta bool
+
+	// Lite fetches only article summaries, which is quicker for counts up to 20.
+	Lite bool
+
+	// Timeout asks the server to give up on articles that take longer to fetch, rounded to
+	// milliseconds.
+	Timeout time.Duration
+
+	// IdempotencyKey is sent in the Idempotency-Key header, so that sending the same pick
+	// again within a day returns the first response instead of new words. When it is empty,
+	// Pick makes up a key for each call, so only its own retries are deduplicated.
+	IdempotencyKey string
+}
+
+// header returns the pick's Idempotency-Key header.
+func (o PickOptions) header() http.Header {
+	key := o.IdempotencyKey
+	if key == "" {
+		key = rand.Text()
+	}
+
+	return http.Header{"Idempotency-Key": {key}}
+}
+
+func (o PickOptions) query() url.Values {
+	query := url.Values{}
+	setString := func(key, value string) {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+	setInt := func(key string, value int) {
+		if value != 0 {
+			query.Set(key, strconv.Itoa(value))
+		}
+	}
+	setBool := func(key string, value *bool) {
+		if value != nil {
+			query.Set(key, strconv.FormatBool(*value))
+		}
+	}
+
+	setString("source", o.Source)
+	setString("project", o.Project)
+	setString("url", o.URL)
+	setString("language", o.Language)
+	setString("fallback", strings.Join(o.Fallback, ","))
+	setInt("count", o.Count)
+	setInt("articles", o.Articles)
+	setInt("ngrams", o.NgramSize)
+	setString("starts_with", o.StartsWith)
+	setString("ends_with", o.EndsWith)
+	setString("pattern", o.Pattern)
+	setString("crossword", o.Crossword)
+	setString("group_by", o.GroupBy)
+	setString("cursor", o.Cursor)
+	setString("bias", o.Bias)
+	setString("script", o.Script)
+	setBool("across_languages", o.AcrossLanguages)
+	setString("prefer_length", o.PreferLength)
+	if o.LengthWeight > 0 {
+		query.Set("length_weight", strconv.FormatFloat(o.LengthWeight, 'g', -1, 64))
+	}
+	setInt("timeout_ms", int(o.Timeout.Milliseconds()))
+	setBool("safe", o.Safe)
+	setBool("unique", o.Unique)
+	if o.Stats {
+		query.Set("stats", "true")
+	}
+	if o.Context {
+		query.Set("context", "true")
+	}
+	if o.Excerpt {
+		query.Set("excerpt", "true")
+	}
+	if o.Difficulty {
+		query.Set("difficulty", "true")
+	}
+	if o.Scrabble {
+		query.Set("scrabble", "true")
+	}
+	if o.Syllables {
+		query.Set("syllables", "true")
+	}
+	if o.Occurrences {
+		query.Set("occurrences", "true")
+	}
+	if o.Audio {
+		query.Set("audio", "true")
+	}
+	if o.Categories {
+		query.Set("categories", "true")
+	}
+	if o.PreserveCase {
+		query.Set("preserve_case", "true")
+	}
+	if o.ForeignScript {
+		query.Set("foreign_script", "true")
+	}
+	if o.Lite {
+		query.Set("lite", "true")
+	}
+	if o.Peek {
+		query.Set("peek", "true")
+	}
+	if o.Debug {
+		query.Set("debug", "true")
+	}
+	if o.Meta {
+		query.Set("meta", "true")
+	}
+
+	return query
+}
+
+// PickResponse is the result of a pick.
+type PickResponse struct {
+	APIVersion         string              `json:"api_version"`
+	Language           string              `json:"language"`
+	Words              []string            `json:"words"`
+	Stats              *Stats              `json:"stats,omitempty"`
+	Requested          int                 `json:"requested"`
+	Delivered          int                 `json:"delivered"`
+	Exhausted          bool                `json:"exhausted"`
+	Warnings           []Warning           `json:"warnings,omitempty"`
+	Cursor             string              `json:"cursor,omitempty"`
+	Peek               bool                `json:"peek,omitempty"`
+	Debug              *Diagnostics        `json:"debug,omitempty"`
+	Meta               *Meta               `json:"meta,omitempty"`
+	ArticleCount       int                 `json:"article_count"`
+	Sources            []Source            `json:"sources,omitempty"`
+	Contexts           map[string]string   `json:"contexts,omitempty"`
+	Excerpt            string              `json:"excerpt,omitempty"`
+	Difficulty         map[string]int      `json:"difficulty,omitempty"`
+	Scrabble           map[string]int      `json:"scrabble,omitempty"`
+	Syllables          map[string]int      `json:"syllables,omitempty"`
+	Occurrences        map[string]int      `json:"occurrences,omitempty"`
+	Audio              map[string][]string `json:"audio,omitempty"`
+	Categories         map[string][]string `json:"categories,omitempty"`
+	Sections           []SectionWords      `json:"sections,omitempty"`
+	LanguageConfidence *float64            `json:"language_confidence,omitempty"`
+	RequestedLanguage  string              `json:"requested_language,omitempty"`
+}
+
+// SectionWords are the picked words found in one section of an article, returned when
+// PickOptions.GroupBy is "section". Section is "" for the lead.
+type SectionWords struct {
+	Article string   `json:"article"`
+	Section string   `json:"section"`
+	Words   []string `json:"words"`
+}
+
+// Source is an article the words of a pick came from. Image is its lead image, if any.
+type Source struct {
+	Title string `json:"title,omitempty"`
+	URL   string `json:"url,omitempty"`
+	Image string `json:"image,omitempty"`
+}
+
+// Stats describes the words extracted for a pick, returned when PickOptions.Stats is set.
+type Stats struct {
+	TotalWords         int         `json:"total_words"`
+	UniqueWords        int         `json:"unique_words"`
+	AlreadyUsed        int         `json:"already_used"`
+	LengthDistribution map[int]int `json:"length_distribution"`
+}
+
+// Diagnostics describes how a pick's words were extracted, returned when PickOptions.Debug
+// is set. Timings are in microseconds.
+type Diagnostics struct {
+	Articles   []ArticleDiagnostics `json:"articles"`
+	Candidates int                  `json:"candidates"`
+	Used       int                  `json:"used"`
+	SampleUS   int64                `json:"sample_us"`
+}
+
+// ArticleDiagnostics describes the extraction of one article: its tokens, how many each
+// filter stage ("safe", "word_lists", "filters") removed, and how long each stage took.
+type ArticleDiagnostics struct {
+	Title      string           `json:"title"`
+	URL        string           `json:"url,omitempty"`
+	Paragraphs int              `json:"paragraphs"`
+	Tokens     int              `json:"tokens"`
+	Kept       int              `json:"kept"`
+	Removed    map[string]int   `json:"removed"`
+	TimingUS   map[string]int64 `json:"timing_us"`
+}
+
+// Meta describes how long a pick took, returned when PickOptions.Meta is set. ArticlesFetched
+// includes articles fetched again for being in another language. Timings are in microseconds.
+type Meta struct {
+	ArticlesFetched int    `json:"articles_fetched"`
+	TimingUS        Timing `json:"timing_us"`
+}
+
+// Timing breaks a pick down into stages.
+type Timing struct {
+	Fetch  int64 `json:"fetch"`
+	Parse  int64 `json:"parse"`
+	Filter int64 `json:"filter"`
+	Sample int64 `json:"sample"`
+	Store  int64 `json:"store"`
+	Total  int64 `json:"total"`
+}
+
+// HistoryEntry is a used word together with where and when it was picked.
+type HistoryEntry struct {
+	Word         string     `json:"word"`
+	Language     string     `json:"language"`
+	ArticleTitle string     `json:"article_title,omitempty"`
+	ArticleURL   string     `json:"article_url,omitempty"`
+	PickedAt     *time.Time `json:"picked_at,omitempty"`
+}
+
+// LanguageStats summarizes the words served for a language.
+type LanguageStats struct {
+	Language               string  `json:"language"`
+	TotalWords             int     `json:"total_words"`
+	WordsToday             int     `json:"words_today"`
+	WordsThisWeek          int     `json:"words_this_week"`
+	Articles               int     `json:"articles"`
+	AverageWordsPerArticle float64 `json:"average_words_per_article"`
+}
+
+// Warning is a condition that didn't stop a pick, such as the use of a fallback language.
+type Warning struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Warning codes reported in Warning.Code.
+const (
+	WarningFallbackLanguage  = "FALLBACK_LANGUAGE"
+	WarningMostWordsFiltered = "MOST_WORDS_FILTERED"
+	WarningStubArticle       = "STUB_ARTICLE"
+	WarningFewerWords        = "FEWER_WORDS"
+)
+
+// WordCount is how often a word occurred in the articles fetched for its language.
+type WordCount struct {
+	Word  string `json:"word"`
+	Count int64  `json:"count"`
+}
+
+// Error codes reported in Error.Code.
+const (
+	CodeInvalidParameter     = "INVALID_PARAMETER"
+	CodeUnsupportedLanguage  = "UNSUPPORTED_LANGUAGE"
+	CodeUnauthorized         = "UNAUTHORIZED"
+	CodeNotFound             = "NOT_FOUND"
+	CodeMethodNotAllowed     = "METHOD_NOT_ALLOWED"
+	CodePoolExhausted        = "POOL_EXHAUSTED"
+	CodeRateLimited          = "RATE_LIMITED"
+	CodeInternalError        = "INTERNAL_ERROR"
+	CodeStoreError           = "STORE_ERROR"
+	CodeUpstreamError        = "UPSTREAM_ERROR"
+	CodeNotEnoughDefinitions = "NOT_ENOUGH_DEFINITIONS"
+	CodeServerBusy           = "SERVER_BUSY"
+	CodeUpstreamUnavailable  = "UPSTREAM_UNAVAILABLE"
+	CodeUpstreamThrottled    = "UPSTREAM_THROTTLED"
+	CodeUpstreamTimeout      = "UPSTREAM_TIMEOUT"
+)
+
+// Error is an error response from the server.
+type Error struct {
+	StatusCode int            `json:"-"`
+	Code       string         `json:"code"`
+	Message    string         `json:"message"`
+	Details    map[string]any `json:"details,omitempty"`
+	RequestID  string         `json:"request_id,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s (%d): %s", e.Code, e.StatusCode, e.Message)
+}
+
+// Pick asks the server for random words.
+func (c *Client) Pick(ctx context.Context, opts PickOptions) (*PickResponse, error) {
+	var response PickResponse
+	if err := c.get(ctx, "/v1/pick", opts.query(), opts.header(), &response); err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
+
+// PickLongpoll is like Pick, but waits up to wait for the server to have an article of the
+// language prefetched. A zero wait uses the server default.
+func (c *Client) PickLongpoll(ctx context.Context, opts PickOptions, wait time.Duration) (*PickResponse, error) {
+	query := opts.query()
+	if wait > 0 {
+		query.Set("wait_ms", strconv.FormatInt(wait.Milliseconds(), 10))
+	}
+
+	var response PickResponse
+	if err := c.get(ctx, "/v1/pick/longpoll", query, opts.header(), &response); err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
+
+// History returns the most recently picked words, newest first. An empty language returns
+// words of every language; a zero limit uses the server default.
+func (c *Client) History(ctx context.Context, language string, limit int) ([]HistoryEntry, error) {
+	return c.HistoryBetween(ctx, language, time.Time{}, time.Time{}, limit)
+}
+
+// HistoryBetween is like History, returning only the words picked from from on and before
+// to. A zero time leaves that end open.
+func (c *Client) HistoryBetween(ctx context.Context, language string, from, to time.Time, limit int) ([]HistoryEntry, error) {
+	query := url.Values{}
+	if language != "" {
+		query.Set("language", language)
+	}
+	if !from.IsZero() {
+		query.Set("from", from.Format(time.RFC3339))
+	}
+	if !to.IsZero() {
+		query.Set("to", to.Format(time.RFC3339))
+	}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+
+	var response struct {
+		Entries []HistoryEntry `json:"entries"`
+	}
+	if err := c.get(ctx, "/v1/history", query, nil, &response); err != nil {
+		return nil, err
+	}
+
+	return response.Entries, nil
+}
+
+// Stats returns usage statistics for the language.
+func (c *Client) Stats(ctx context.Context, language string) (*LanguageStats, error) {
+	var response LanguageStats
+	if err := c.get(ctx, "/v1/stats", url.Values{"language": {language}}, nil, &response); err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
+
+// TopWords returns the n most frequent words in the articles the server has fetched for the
+// language, most frequent first. A zero n uses the server default.
+func (c *Client) TopWords(ctx context.Context, language string, n int) ([]WordCount, error) {
+	query := url.Values{"language": {language}}
+	if n > 0 {
+		query.Set("n", strconv.Itoa(n))
+	}
+
+	var response struct {
+		Words []WordCount `json:"words"`
+	}
+	if err := c.get(ctx, "/v1/analytics/top", query, nil, &response); err != nil {
+		return nil, err
+	}
+
+	return response.Words, nil
+}
+
+// get sends a GET request with the header and decodes the JSON response into out, retrying
+// network errors and gateway failures.
+func (c *Client) get(ctx context.Context, path string, query url.Values, header http.Header, out any) error {
+	endpoint := c.BaseURL + path
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+
+	backoff := c.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		err := c.do(ctx, endpoint, header, out)
+		if err == nil || attempt >= c.MaxRetries || !retryable(err) {
+			return err
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		backoff *= 2
+	}
+}
+
+func (c *Client) do(ctx context.Context, endpoint string, header http.Header, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	if c.APIKey != "" {
+		req.Header.Set("X-API-Key", c.APIKey)
+	}
+	if c.Namespace != "" {
+		req.Header.Set("X-Namespace", c.Namespace)
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		apiErr := &Error{StatusCode: resp.StatusCode}
+		if err := json.NewDecoder(resp.Body).Decode(apiErr); err != nil {
+			apiErr.Message = resp.Status
+		}
+		return apiErr
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// retryable reports whether a failed request may succeed if sent again.
+func retryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		switch apiErr.StatusCode {
+		case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/xml"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"unicode/utf8"
+)
+
+// Dimensions of the word cloud images, in pixels.
+const (
+	cloudWidth       = 800
+	cloudHeight      = 500
+	cloudMinFontSize = 14
+	cloudMaxFontSize = 64
+)
+
+var cloudColors = []string{"#3366cc", "#14866d", "#ac6600", "#dd3333", "#6b4ba1", "#202122"}
+
+// cloudHandler picks words and renders them as an SVG word cloud, each word sized by how often
+// it occurs in the source articles. It takes the /pick parameters, except that `count`
+// defaults to 50 and `unique` to false, so an embedded image doesn't use up words each time
+// it is loaded.
+func cloudHandler(w http.ResponseWriter, r *http.Request) {
+	opts, err := parsePickOptions(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	query := r.URL.Query()
+	if query.Get("count") == "" {
+		opts.Count = min(50, maxCount)
+	}
+	if query.Get("unique") == "" {
+		opts.Unique = false
+	}
+	opts.Occurrences = true
+
+	response, err := pick(r.Context(), opts)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/svg+xml")
+	w.Write(renderCloud(response.Words, response.Occurrences))
+}
+
+// cloudBox is the space taken by a placed word.
+type cloudBox struct {
+	x, y, width, height float64
+}
+
+func (b cloudBox) overlaps(other cloudBox) bool {
+	return math.Abs(b.x-other.x)*2 < b.width+other.width && math.Abs(b.y-other.y)*2 < b.height+other.height
+}
+
+// renderCloud lays the words out on a spiral from the centre, largest first, and returns the
+// SVG. Words that don't fit are left out. Text widths are estimated, as the font is up to
+// the viewer.
+func renderCloud(words []string, weights map[string]int) []byte {
+	sorted := slices.Clone(words)
+	slices.SortStableFunc(sorted, func(a, b string) int { return cmp.Compare(weights[b], weights[a]) })
+
+	heaviest := 1
+	for _, word := range sorted {
+		heaviest = max(heaviest, weights[word])
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="sans-serif" text-anchor="middle" dominant-baseline="central">`+"\n",
+		cloudWidth, cloudHeight, cloudWidth, cloudHeight)
+
+	var placed []cloudBox
+	for i, word := range sorted {
+		scale := 0.0
+		if heaviest > 1 {
+			scale = math.Log(float64(max(weights[word], 1))) / math.Log(float64(heaviest))
+		}
+		size := cloudMinFontSize + scale*(cloudMaxFontSize-cloudMinFontSize)
+		box := cloudBox{width: 0.6 * size * float64(utf8.RuneCountInString(word)), height: size}
+
+		// Walk out along an elliptical spiral until the word fits without overlapping.
+		fits := false
+		for t := 0.0; t < 200; t += 0.1 {
+			box.x = cloudWidth/2 + 4*t*math.Cos(t)
+			box.y = cloudHeight/2 + 4*t*math.Sin(t)*cloudHeight/cloudWidth
+			if box.x-box.width/2 < 0 || box.x+box.width/2 > cloudWidth || box.y-box.height/2 < 0 || box.y+box.height/2 > cloudHeight {
+				continue
+			}
+			if !slices.ContainsFunc(placed, box.overlaps) {
+				fits = true
+				break
+			}
+		}
+		if !fits {
+			continue
+		}
+		placed = append(placed, box)
+
+		fmt.Fprintf(&buf, `<text x="%.1f" y="%.1f" font-size="%.1f" fill="%s">`, box.x, box.y, size, cloudColors[i%len(cloudColors)])
+		xml.EscapeText(&buf, []byte(word))
+		buf.WriteString("</text>\n")
+	}
+	buf.WriteString("</svg>\n")
+
+	return buf.Bytes()
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/url"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// Config is the JSON file given with -config. It points the sources at mirrors or other
+// MediaWiki instances and adjusts limits and filters; every field is optional.
+type Config struct {
+	// RandomArticleURLs overrides or adds the address redirecting to a random article of a
+	// language's edition. Adding a language makes it available to wikipedia picks.
+	RandomArticleURLs map[string]string `json:"random_article_urls"`
+
+	// APIURLs overrides the action API endpoint of a language's edition, which is otherwise
+	// /w/api.php on the host of its random article address.
+	APIURLs map[string]string `json:"api_urls"`
+
+	// WiktionaryDefinitionURL overrides the REST endpoint definitions are looked up at.
+	WiktionaryDefinitionURL string `json:"wiktionary_definition_url"`
+
+	// RateLimit overrides -rate-limit.
+	RateLimit *int `json:"rate_limit"`
+
+	// OffensiveWords adds words to the embedded lists that safe picks remove, per language.
+	OffensiveWords map[string][]string `json:"offensive_words"`
+
+	// ExcludedClasses replaces the classes and ids of the page elements skipped when
+	// extracting article HTML.
+	ExcludedClasses []string `json:"excluded_classes"`
+
+	// CrossLanguageNamespaces lists the namespaces whose picks are unique across languages
+	// by default, as if they set `across_languages=true`.
+	CrossLanguageNamespaces []string `json:"cross_language_namespaces"`
+
+	// URLDomains lists the hosts, besides the configured editions, whose pages a pick may
+	// name with `url`, such as "wiki.example.org", or "*.example.org" for its subdomains.
+	URLDomains []string `json:"url_domains"`
+}
+
+// configMu guards the settings a config reload replaces.
+var configMu sync.RWMutex
+
+// apiURLByLanguage holds the API endpoints set in the config file, guarded by configMu.
+var apiURLByLanguage = map[string]string{}
+
+// crossLanguageNamespaces holds the namespaces set in the config file, guarded by configMu.
+var crossLanguageNamespaces = map[string]bool{}
+
+// urlDomains holds the URL domains set in the config file, guarded by configMu.
+var urlDomains []string
+
+// loadConfig reads and checks a config file.
+func loadConfig(path string) (*Config, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var config Config
+	decoder := json.NewDecoder(file)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&config); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	urls := map[string]string{"wiktionary_definition_url": config.WiktionaryDefinitionURL}
+	for language, address := range config.RandomArticleURLs {
+		urls["random_article_urls."+language] = address
+	}
+	for language, address := range config.APIURLs {
+		urls["api_urls."+language] = address
+	}
+	for field, address := range urls {
+		if address == "" {
+			continue
+		}
+		if parsed, err := url.Parse(address); err != nil || parsed.Scheme == "" || parsed.Host == "" {
+			return nil, fmt.Errorf("%s: %s is not an absolute URL: %q", path, field, address)
+		}
+	}
+	if config.RateLimit != nil && *config.RateLimit < 0 {
+		return nil, fmt.Errorf("%s: rate_limit must not be negative", path)
+	}
+	for _, namespace := range config.CrossLanguageNamespaces {
+		if !validNamespace(namespace) {
+			return nil, fmt.Errorf("%s: cross_language_namespaces: invalid namespace %q", path, namespace)
+		}
+	}
+	for _, domain := range config.URLDomains {
+		if host := strings.TrimPrefix(domain, "*."); host == "" || strings.ContainsAny(host, "*/:") {
+			return nil, fmt.Errorf("%s: url_domains: invalid domain %q", path, domain)
+		}
+	}
+
+	return &config, nil
+}
+
+// apply makes the config take effect, replacing whatever an earlier config set. Requests
+// already running keep the settings they started with.
+func (c *Config) apply() {
+	randomArticleURLs := maps.Clone(defaultRandomArticleURLs)
+	maps.Copy(randomArticleURLs, c.RandomArticleURLs)
+
+	definitionURL := defaultWiktionaryDefinitionURL
+	if c.WiktionaryDefinitionURL != "" {
+		definitionURL = c.WiktionaryDefinitionURL
+	}
+
+	offensive := loadProfanityLists()
+	for language, words := range c.OffensiveWords {
+		if offensive[language] == nil {
+			offensive[language] = make(map[string]struct{})
+		}
+		for _, word := range words {
+			if word = ToLowerLanguage(strings.TrimSpace(word), language); word != "" {
+				offensive[language][word] = struct{}{}
+			}
+		}
+	}
+
+	excluded := defaultExcludedClasses
+	if c.ExcludedClasses != nil {
+		excluded = c.ExcludedClasses
+	}
+
+	crossLanguage := make(map[string]bool, len(c.CrossLanguageNamespaces))
+	for _, namespace := range c.CrossLanguageNamespaces {
+		crossLanguage[namespace] = true
+	}
+
+	configMu.Lock()
+	randomArticleURLByLanguage = randomArticleURLs
+	crossLanguageNamespaces = crossLanguage
+	urlDomains = slices.Clone(c.URLDomains)
+	apiURLByLanguage = maps.Clone(c.APIURLs)
+	wiktionaryDefinitionURL = definitionURL
+	profanityByLanguage = offensive
+	excludedClasses = classSet(excluded)
+	configMu.Unlock()
+
+	limit := rateLimitPerMinute
+	if c.RateLimit != nil {
+		limit = *c.RateLimit
+	}
+	requestLimiter.setLimit(limit)
+}
+
+// reloadConfigOnHangup reloads the config file whenever the process receives SIGHUP. A file
+// that fails to load is logged and the running config is kept.
+func reloadConfigOnHangup(path string) {
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+
+	for range hangups {
+		config, err := loadConfig(path)
+		if err != nil {
+			slog.Error("Failed to reload config file, keeping the current one", "error", err)
+			continue
+		}
+		config.apply()
+		slog.Info("Reloaded config file", "path", path)
+	}
+}
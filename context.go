@@ -0,0 +1,93 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+)
+
+// SplitSentences splits a paragraph into sentences at '.', '!' and '?' followed by whitespace.
+// Whitespace inside each sentence is collapsed to single spaces.
+func SplitSentences(paragraph string) []string {
+	var sentences []string
+
+	runes := []rune(paragraph)
+	start := 0
+	for i, r := range runes {
+		if r != '.' && r != '!' && r != '?' {
+			continue
+		}
+		if i+1 < len(runes) && !unicode.IsSpace(runes[i+1]) {
+			continue
+		}
+
+		sentences = appendSentence(sentences, string(runes[start:i+1]))
+		start = i + 1
+	}
+	sentences = appendSentence(sentences, string(runes[start:]))
+
+	return sentences
+}
+
+func appendSentence(sentences []string, sentence string) []string {
+	sentence = strings.Join(strings.Fields(sentence), " ")
+	if sentence == "" {
+		return sentences
+	}
+
+	return append(sentences, sentence)
+}
+
+// FindContexts returns, for each word or phrase, the first sentence of the paragraphs in which it appears.
+func FindContexts(paragraphs []string, words []string, language string) map[string]string {
+	contexts := make(map[string]string, len(words))
+	for _, paragraph := range paragraphs {
+		for _, sentence := range SplitSentences(paragraph) {
+			cleaned := " " + strings.Join(strings.Fields(RemovePunctuation(sentence, language)), " ") + " "
+			for _, word := range words {
+				if _, found := contexts[word]; found {
+					continue
+				}
+				if strings.Contains(cleaned, " "+word+" ") {
+					contexts[word] = sentence
+				}
+			}
+			if len(contexts) == len(words) {
+				return contexts
+			}
+		}
+	}
+
+	return contexts
+}
+
+// NgramsFromParagraphs returns every sequence of n consecutive words, joined by spaces.
+// Sequences never cross sentence boundaries.
+func NgramsFromParagraphs(paragraphs []string, n int, language string) []string {
+	return ngrams(paragraphs, n, func(s string) string { return RemovePunctuation(s, language) })
+}
+
+// ngrams returns the sequences of n consecutive words of the sentences after clean.
+func ngrams(paragraphs []string, n int, clean func(string) string) []string {
+	var ngrams []string
+	for _, paragraph := range paragraphs {
+		for _, sentence := range SplitSentences(paragraph) {
+			words := strings.Fields(clean(sentence))
+			for i := 0; i+n <= len(words); i++ {
+				ngrams = append(ngrams, strings.Join(words[i:i+n], " "))
+			}
+		}
+	}
+
+	return ngrams
+}
+
+// FirstParagraph returns the first non-blank paragraph with its whitespace collapsed.
+func FirstParagraph(paragraphs []string) string {
+	for _, paragraph := range paragraphs {
+		if text := strings.Join(strings.Fields(paragraph), " "); text != "" {
+			return text
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// corpusFallback makes picks from the wikipedia source fall back to the embedded corpus when
+// Wikipedia can't be reached.
+var corpusFallback bool
+
+// corpusSource picks words from the word lists embedded in the binary, the same lists that
+// rate word difficulty. It needs no network or database, so it keeps picks working while
+// Wikipedia is down; uniqueness still applies.
+type corpusSource struct{}
+
+func init() {
+	RegisterWordSource("corpus", corpusSource{})
+}
+
+func (corpusSource) Supports(language string) bool {
+	return len(frequencyRanks[language]) > 0
+}
+
+// Fetch returns the language's whole word list as one article.
+func (corpusSource) Fetch(ctx context.Context, language string) (*Article, error) {
+	ranks := frequencyRanks[language]
+	if len(ranks) == 0 {
+		return nil, fmt.Errorf("no embedded corpus for language: %s", language)
+	}
+
+	words := slices.SortedFunc(maps.Keys(ranks), func(a, b string) int {
+		return ranks[a] - ranks[b]
+	})
+
+	return &Article{
+		Title:      "Embedded word list",
+		Paragraphs: []string{strings.Join(words, " ")},
+	}, nil
+}
+
+// isUpstreamFailure reports whether err means Wikipedia couldn't be reached.
+func isUpstreamFailure(err error) bool {
+	var apiErr *apiError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+
+	return apiErr.Code == CodeUpstreamError || apiErr.Code == CodeUpstreamTimeout || apiErr.Code == CodeUpstreamUnavailable ||
+		apiErr.Code == CodeUpstreamThrottled
+}
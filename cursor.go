@@ -0,0 +1,100 @@
+package main
+
+import (
+	"crypto/rand"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// newCursor is the `cursor` value that starts a cursor.
+const newCursor = "new"
+
+// cursorTTL is how long a cursor is kept after it was started.
+const cursorTTL = 30 * 24 * time.Hour
+
+// A cursor remembers every word picked with it, so that a client paging through batches with
+// the same cursor never gets a word twice, whether or not the picks are unique. Cursors are
+// stored, so they survive restarts, and belong to the namespace they were started in.
+func initCursorTables() error {
+	for _, statement := range []string{
+		`CREATE TABLE IF NOT EXISTS cursors (id TEXT PRIMARY KEY,namespace TEXT NOT NULL DEFAULT '',created_at DATETIME)`,
+		`CREATE TABLE IF NOT EXISTS cursor_words (cursor TEXT,word_key TEXT,PRIMARY KEY(cursor, word_key))`,
+	} {
+		if _, err := db.Exec(statement); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// createCursor starts a cursor in the namespace and returns its token.
+func createCursor(tx dbtx, namespace string) (string, error) {
+	id := rand.Text()
+	_, err := tx.Exec("INSERT INTO cursors(id,namespace,created_at) VALUES (?,?,?)", id, namespace, time.Now().UTC())
+	return id, err
+}
+
+// getCursorWords returns the keys of the words picked with the cursor, as given by wordKey.
+func getCursorWords(tx dbtx, namespace string, cursor string) (map[string]struct{}, error) {
+	rows, err := tx.Query(`SELECT cursor_words.word_key FROM cursors LEFT JOIN cursor_words ON cursor_words.cursor=cursors.id
+		WHERE cursors.id=? AND cursors.namespace=?`, cursor, namespace)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	found := false
+	words := make(map[string]struct{})
+	for rows.Next() {
+		found = true
+		var key *string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		if key != nil {
+			words[*key] = struct{}{}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, &apiError{
+			Status:  http.StatusNotFound,
+			Code:    CodeNotFound,
+			Message: fmt.Sprintf("unknown or expired cursor: %s", cursor),
+			Details: map[string]any{"cursor": cursor},
+		}
+	}
+
+	return words, nil
+}
+
+// recordCursorWords adds the words to those picked with the cursor.
+func recordCursorWords(tx dbtx, cursor string, words []string) error {
+	stmt, err := tx.Prepare("INSERT OR IGNORE INTO cursor_words(cursor,word_key) VALUES (?,?)")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, word := range words {
+		if _, err := stmt.Exec(cursor, wordKey(word)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// pruneCursors deletes the cursors started before cutoff and their words.
+func pruneCursors(tx dbtx, cutoff time.Time) error {
+	_, err := tx.Exec("DELETE FROM cursor_words WHERE cursor IN (SELECT id FROM cursors WHERE created_at < ?)", cutoff.UTC())
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("DELETE FROM cursors WHERE created_at < ?", cutoff.UTC())
+	return err
+}
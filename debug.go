@@ -0,0 +1,27 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/pprof"
+)
+
+// serveDebug serves the pprof handlers on their own address, behind the admin token.
+// It is meant for a port that isn't exposed publicly.
+func serveDebug(addr string) {
+	if adminToken == "" {
+		slog.Warn("Debug endpoints need -admin-token and will answer 404 without it")
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	slog.Info("Serving debug endpoints", "addr", addr)
+	if err := http.ListenAndServe(addr, Chain(mux, recoverPanics, requireAdmin)); err != nil {
+		slog.Error("Debug server stopped", "error", err)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import "time"
+
+// Diagnostics describes how a pick's candidate words were extracted, returned when
+// `debug=true` to help tune filters and report extraction bugs.
+type Diagnostics struct {
+	Articles []*ArticleDiagnostics `json:"articles"`
+
+	// Candidates counts the distinct words left after the filters, and Used those of them
+	// that were left out for being used before or picked with the cursor.
+	Candidates int `json:"candidates"`
+	Used       int `json:"used"`
+
+	// SampleUS is how long drawing the words took, in microseconds.
+	SampleUS int64 `json:"sample_us"`
+}
+
+// ArticleDiagnostics describes the extraction of one article.
+type ArticleDiagnostics struct {
+	Title      string `json:"title"`
+	URL        string `json:"url,omitempty"`
+	Paragraphs int    `json:"paragraphs"`
+
+	// Tokens counts the words, or n-grams, split from the paragraphs before any filter, and
+	// Kept those left after every filter.
+	Tokens int `json:"tokens"`
+	Kept   int `json:"kept"`
+
+	// Removed counts the tokens each filter stage removed: "safe" (offensive words),
+	// "word_lists" (blocklist and allowlist) and "filters" (starts_with, ends_with, pattern,
+	// crossword and script).
+	Removed map[string]int `json:"removed"`
+
+	// TimingUS is how long fetching the article and each stage took, in microseconds.
+	TimingUS map[string]int64 `json:"timing_us"`
+}
+
+// addArticle starts the diagnostics of an article that took fetched to fetch. A nil
+// Diagnostics records nothing and returns nil.
+func (d *Diagnostics) addArticle(article *Article, fetched time.Duration) *ArticleDiagnostics {
+	if d == nil {
+		return nil
+	}
+
+	diagnostics := &ArticleDiagnostics{
+		Title:      article.Title,
+		URL:        article.URL,
+		Paragraphs: len(article.Paragraphs),
+		Removed:    make(map[string]int),
+		TimingUS:   map[string]int64{"fetch": fetched.Microseconds()},
+	}
+	d.Articles = append(d.Articles, diagnostics)
+
+	return diagnostics
+}
+
+// tokenized records the tokens split from the article since start.
+func (d *ArticleDiagnostics) tokenized(start time.Time, tokens int) {
+	if d == nil {
+		return
+	}
+
+	d.Tokens, d.Kept = tokens, tokens
+	d.TimingUS["tokenize"] = time.Since(start).Microseconds()
+}
+
+// filtered records a filter stage that ran since start and left kept tokens.
+func (d *ArticleDiagnostics) filtered(stage string, start time.Time, kept int) {
+	if d == nil {
+		return
+	}
+
+	d.Removed[stage] = d.Kept - kept
+	d.Kept = kept
+	d.TimingUS[stage] = time.Since(start).Microseconds()
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bufio"
+	"embed"
+	"math"
+	"strings"
+)
+
+//go:embed frequency/*.txt
+var frequencyFiles embed.FS
+
+// frequencyRanks maps the most common words of each language to their frequency rank,
+// starting at 1.
+var frequencyRanks = loadFrequencyRanks()
+
+// loadFrequencyRanks reads frequency/<language>.txt, which lists one word per line from
+// most to least common.
+func loadFrequencyRanks() map[string]map[string]int {
+	ranks := make(map[string]map[string]int)
+
+	entries, err := frequencyFiles.ReadDir("frequency")
+	if err != nil {
+		return ranks
+	}
+
+	for _, entry := range entries {
+		language := strings.TrimSuffix(entry.Name(), ".txt")
+		file, err := frequencyFiles.Open("frequency/" + entry.Name())
+		if err != nil {
+			continue
+		}
+
+		words := make(map[string]int)
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			word := strings.ToLower(strings.TrimSpace(scanner.Text()))
+			if _, found := words[word]; word != "" && !found {
+				words[word] = len(words) + 1
+			}
+		}
+		file.Close()
+
+		ranks[language] = words
+	}
+
+	return ranks
+}
+
+// ZipfFrequency estimates the Zipf frequency of a word, the base-10 logarithm of its
+// occurrences per billion words, from its rank by Zipf's law. Words missing from the
+// language's list score 0.
+func ZipfFrequency(word string, language string) float64 {
+	rank, found := frequencyRanks[language][word]
+	if !found {
+		return 0
+	}
+
+	return 8 - math.Log10(float64(rank))
+}
+
+// Difficulty rates a word from 1 (among the most common words) to 5 (rare or unknown). A
+// phrase is as difficult as its rarest word.
+func Difficulty(word string, language string) int {
+	zipf := phraseZipfFrequency(word, language)
+
+	switch {
+	case zipf >= 6.5:
+		return 1
+	case zipf >= 6:
+		return 2
+	case zipf >= 5.5:
+		return 3
+	case zipf >= 5:
+		return 4
+	default:
+		return 5
+	}
+}
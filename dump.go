@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bufio"
+	"compress/bzip2"
+	"context"
+	"database/sql"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// dumpSource picks words from articles imported from a Wikipedia dump with -import-dump,
+// so the service can run without access to Wikipedia.
+type dumpSource struct{}
+
+func init() {
+	RegisterWordSource("dump", dumpSource{})
+}
+
+func initDumpTables() error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS dump_articles (id INTEGER PRIMARY KEY,language TEXT,title TEXT,text TEXT)`)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`CREATE INDEX IF NOT EXISTS dump_articles_language_id ON dump_articles(language, id)`)
+	return err
+}
+
+func (dumpSource) Supports(language string) bool {
+	var found int
+	err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM dump_articles WHERE language=?)", language).Scan(&found)
+	return err == nil && found == 1
+}
+
+// Fetch returns a random imported article. It picks a random id between the lowest and highest
+// id for the language and takes the first article at or after it, which avoids scanning the table.
+func (dumpSource) Fetch(ctx context.Context, language string) (*Article, error) {
+	var low, high sql.NullInt64
+	err := db.QueryRowContext(ctx, "SELECT MIN(id), MAX(id) FROM dump_articles WHERE language=?", language).Scan(&low, &high)
+	if err != nil {
+		return nil, err
+	}
+	if !low.Valid {
+		return nil, &apiError{
+			Status:  http.StatusNotFound,
+			Code:    CodeUnsupportedLanguage,
+			Message: fmt.Sprintf("no articles imported for language: %s", language),
+		}
+	}
+
+	id := low.Int64 + rand.Int63n(high.Int64-low.Int64+1)
+	var title, text string
+	err = db.QueryRowContext(ctx, "SELECT title, text FROM dump_articles WHERE language=? AND id>=? ORDER BY id LIMIT 1", language, id).Scan(&title, &text)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Article{Title: title, Paragraphs: splitParagraphs(text)}, nil
+}
+
+// splitParagraphs splits plain text into paragraphs at blank lines.
+func splitParagraphs(text string) []string {
+	var paragraphs []string
+	for _, paragraph := range strings.Split(strings.ReplaceAll(cleanText(text), "\r\n", "\n"), "\n\n") {
+		if paragraph = strings.TrimSpace(paragraph); paragraph != "" {
+			paragraphs = append(paragraphs, paragraph)
+		}
+	}
+
+	return paragraphs
+}
+
+// importDump indexes a Wikipedia dump into dump_articles and returns the number of articles
+// imported. It accepts a pages-articles XML dump or WikiExtractor output (<doc> elements with
+// plain text), optionally bzip2-compressed.
+func importDump(path string, language string) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	var r io.Reader = bufio.NewReader(file)
+	if strings.HasSuffix(path, ".bz2") {
+		r = bzip2.NewReader(r)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare("INSERT INTO dump_articles(language,title,text) VALUES (?,?,?)")
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	imported := 0
+	err = readDump(r, func(title, text string) error {
+		if strings.TrimSpace(text) == "" {
+			return nil
+		}
+		if _, err := stmt.Exec(language, title, text); err != nil {
+			return err
+		}
+		imported++
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return imported, tx.Commit()
+}
+
+// dumpPage is a <page> element of a pages-articles dump.
+type dumpPage struct {
+	Title     string    `xml:"title"`
+	Namespace int       `xml:"ns"`
+	Redirect  *struct{} `xml:"redirect"`
+	Text      string    `xml:"revision>text"`
+}
+
+// readDump calls article with the title and plain text of every article in the dump.
+// Pages outside the main namespace and redirects are skipped.
+func readDump(r io.Reader, article func(title, text string) error) error {
+	decoder := xml.NewDecoder(r)
+	// WikiExtractor output is a sequence of <doc> elements without a root element.
+	decoder.Strict = false
+
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read dump: %w", err)
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		switch start.Name.Local {
+		case "page":
+			var page dumpPage
+			if err := decoder.DecodeElement(&page, &start); err != nil {
+				return fmt.Errorf("failed to read dump page: %w", err)
+			}
+			if page.Namespace != 0 || page.Redirect != nil {
+				continue
+			}
+			if err := article(page.Title, WikitextToPlain(page.Text)); err != nil {
+				return err
+			}
+
+		case "doc":
+			var doc struct {
+				Title string `xml:"title,attr"`
+				Text  string `xml:",chardata"`
+			}
+			if err := decoder.DecodeElement(&doc, &start); err != nil {
+				return fmt.Errorf("failed to read dump document: %w", err)
+			}
+			// WikiExtractor repeats the title as the first line of the text.
+			text := strings.TrimPrefix(strings.TrimSpace(doc.Text), doc.Title)
+			if err := article(doc.Title, text); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+var (
+	wikiComment   = regexp.MustCompile(`(?s)<!--.*?-->`)
+	wikiRef       = regexp.MustCompile(`(?s)<ref[^>/]*/>|<ref[^>]*>.*?</ref>`)
+	wikiMath      = regexp.MustCompile(`(?s)<math[\s>].*?</math>|<chem>.*?</chem>|<ce>.*?</ce>`)
+	wikiTag       = regexp.MustCompile(`<[^>]+>`)
+	wikiTemplate  = regexp.MustCompile(`\{\{[^{}]*\}\}`)
+	wikiTable     = regexp.MustCompile(`(?s)\{\|.*?\|\}`)
+	wikiFileLink  = regexp.MustCompile(`\[\[(?i:file|image|category|datei|fichier|kategorie|catégorie):[^\[\]]*(\[\[[^\]]*\]\][^\[\]]*)*\]\]`)
+	wikiLink      = regexp.MustCompile(`\[\[(?:[^|\]]*\|)?([^\]]*)\]\]`)
+	wikiExternal  = regexp.MustCompile(`\[https?://[^\s\]]*\s?([^\]]*)\]`)
+	wikiHeading   = regexp.MustCompile(`(?m)^=+.*?=+\s*$`)
+	wikiListStart = regexp.MustCompile(`(?m)^[*#:;]+\s*`)
+	wikiEmphasis  = regexp.MustCompile(`'{2,}`)
+)
+
+// WikitextToPlain strips the common wikitext markup from an article, keeping the prose.
+// Headings become paragraph breaks; templates (such as {{coord}}), tables, references, formulas
+// and files are dropped.
+func WikitextToPlain(text string) string {
+	text = wikiComment.ReplaceAllString(text, "")
+	text = wikiRef.ReplaceAllString(text, "")
+	text = wikiMath.ReplaceAllString(text, "")
+	// Templates nest, so strip the innermost ones until none are left.
+	for wikiTemplate.MatchString(text) {
+		text = wikiTemplate.ReplaceAllString(text, "")
+	}
+	text = wikiTable.ReplaceAllString(text, "")
+	text = wikiFileLink.ReplaceAllString(text, "")
+	text = wikiLink.ReplaceAllString(text, "$1")
+	text = wikiExternal.ReplaceAllString(text, "$1")
+	text = wikiHeading.ReplaceAllString(text, "\n")
+	text = wikiListStart.ReplaceAllString(text, "")
+	text = wikiEmphasis.ReplaceAllString(text, "")
+	text = wikiTag.ReplaceAllString(text, "")
+
+	return strings.TrimSpace(text)
+}
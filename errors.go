@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"modernc.org/sqlite"
+)
+
+// ErrorCode identifies the kind of failure in the error envelope, so clients can branch on it
+// without parsing messages.
+type ErrorCode string
+
+const (
+	// CodeInvalidParameter: a query parameter or request body failed validation (400).
+	CodeInvalidParameter ErrorCode = "INVALID_PARAMETER"
+	// CodeUnsupportedLanguage: neither the language nor a fallback can be picked from (400).
+	CodeUnsupportedLanguage ErrorCode = "UNSUPPORTED_LANGUAGE"
+	// CodeUnauthorized: a missing or invalid API key, access token or admin token (401).
+	CodeUnauthorized ErrorCode = "UNAUTHORIZED"
+	// CodeNotFound: no such endpoint or record (404).
+	CodeNotFound ErrorCode = "NOT_FOUND"
+	// CodeMethodNotAllowed: the endpoint doesn't accept the HTTP method (405).
+	CodeMethodNotAllowed ErrorCode = "METHOD_NOT_ALLOWED"
+	// CodePoolExhausted: the articles had no word left to pick after filtering (409).
+	CodePoolExhausted ErrorCode = "POOL_EXHAUSTED"
+	// CodeRateLimited: the client used up its request allowance (429).
+	CodeRateLimited ErrorCode = "RATE_LIMITED"
+	// CodeInternalError: an unexpected failure in the server (500).
+	CodeInternalError ErrorCode = "INTERNAL_ERROR"
+	// CodeStoreError: the word database failed (500).
+	CodeStoreError ErrorCode = "STORE_ERROR"
+	// CodeUpstreamError: Wikipedia or Wiktionary answered with an error or unreadable data (502).
+	CodeUpstreamError ErrorCode = "UPSTREAM_ERROR"
+	// CodeNotEnoughDefinitions: too few quiz words have a Wiktionary definition (502).
+	CodeNotEnoughDefinitions ErrorCode = "NOT_ENOUGH_DEFINITIONS"
+	// CodeServerBusy: no fetch slot became free in time, or the word database stayed locked (503).
+	CodeServerBusy ErrorCode = "SERVER_BUSY"
+	// CodeUpstreamUnavailable: fetches from the upstream are paused by its circuit breaker (503).
+	CodeUpstreamUnavailable ErrorCode = "UPSTREAM_UNAVAILABLE"
+	// CodeUpstreamThrottled: Wikimedia asked us to slow down, with a 429 or because its
+	// databases lag, and fetches from it are paused (503).
+	CodeUpstreamThrottled ErrorCode = "UPSTREAM_THROTTLED"
+	// CodeUpstreamTimeout: Wikipedia or Wiktionary didn't answer in time (504).
+	CodeUpstreamTimeout ErrorCode = "UPSTREAM_TIMEOUT"
+)
+
+// ErrorResponse is the JSON envelope every endpoint uses to report a failure.
+type ErrorResponse struct {
+	Code    ErrorCode      `json:"code"`
+	Message string         `json:"message"`
+	Details map[string]any `json:"details,omitempty"`
+
+	// RequestID identifies the failed request in the server logs.
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// apiError is an error that knows which HTTP status and envelope to report to the client.
+type apiError struct {
+	Status  int
+	Code    ErrorCode
+	Message string
+	Details map[string]any
+}
+
+func (e *apiError) Error() string {
+	return e.Message
+}
+
+// invalidParameter reports a query parameter that failed validation.
+func invalidParameter(parameter string, format string, args ...any) *apiError {
+	return &apiError{
+		Status:  http.StatusBadRequest,
+		Code:    CodeInvalidParameter,
+		Message: fmt.Sprintf(format, args...),
+		Details: map[string]any{"parameter": parameter},
+	}
+}
+
+// upstreamError reports a failure to fetch or read an article from Wikipedia.
+func upstreamError(err error) *apiError {
+	var throttleErr *throttleError
+	if errors.As(err, &throttleErr) {
+		return &apiError{
+			Status:  http.StatusServiceUnavailable,
+			Code:    CodeUpstreamThrottled,
+			Message: throttleErr.Error(),
+			Details: map[string]any{"retry_after_seconds": int(math.Ceil(max(time.Until(throttleErr.until), 0).Seconds()))},
+		}
+	}
+
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || errors.As(err, &netErr) && netErr.Timeout() {
+		return &apiError{
+			Status:  http.StatusGatewayTimeout,
+			Code:    CodeUpstreamTimeout,
+			Message: err.Error(),
+		}
+	}
+
+	return &apiError{
+		Status:  http.StatusBadGateway,
+		Code:    CodeUpstreamError,
+		Message: err.Error(),
+	}
+}
+
+// writeError writes err as a JSON error envelope. Database errors are reported as store
+// errors, and any other error that isn't an *apiError as an internal server error.
+func writeError(w http.ResponseWriter, err error) {
+	var apiErr *apiError
+	var sqliteErr *sqlite.Error
+	switch {
+	case errors.As(err, &apiErr):
+	case errors.As(err, &sqliteErr), errors.Is(err, sql.ErrConnDone), errors.Is(err, sql.ErrTxDone):
+		apiErr = &apiError{
+			Status:  http.StatusInternalServerError,
+			Code:    CodeStoreError,
+			Message: err.Error(),
+		}
+	default:
+		apiErr = &apiError{
+			Status:  http.StatusInternalServerError,
+			Code:    CodeInternalError,
+			Message: err.Error(),
+		}
+	}
+
+	// Errors that know when to try again, like a paused upstream, say so in the standard way.
+	if seconds, ok := apiErr.Details["retry_after_seconds"].(int); ok {
+		w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(apiErr.Status)
+	json.NewEncoder(w).Encode(ErrorResponse{
+		Code:      apiErr.Code,
+		Message:   apiErr.Message,
+		Details:   apiErr.Details,
+		RequestID: w.Header().Get("X-Request-ID"),
+	})
+}
+
+// notFoundHandler reports unknown paths in the JSON error envelope.
+func notFoundHandler(w http.ResponseWriter, r *http.Request) {
+	writeError(w, &apiError{
+		Status:  http.StatusNotFound,
+		Code:    CodeNotFound,
+		Message: fmt.Sprintf("no endpoint at %s", r.URL.Path),
+	})
+}
@@ -0,0 +1,344 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/html"
+)
+
+// ExtractWordsFromParagraphs parses HTML content, extracts text from <p> tags,
+// and returns a slice of all words found within those paragraphs, lowercased by the rules of
+// the language.
+func ExtractWordsFromParagraphs(htmlContent string, language string) ([]string, error) {
+	paragraphs, err := ExtractParagraphs(strings.NewReader(htmlContent))
+	if err != nil {
+		return nil, err
+	}
+
+	return WordsFromParagraphs(paragraphs, language), nil
+}
+
+// Article is the text extracted from a Wikipedia article page.
+type Article struct {
+	Title      string
+	URL        string
+	Paragraphs []string
+
+	// Image is the URL of the article's lead image, if the source provides one.
+	Image string
+
+	// Categories are the names of the article's visible categories, without the namespace.
+	Categories []string
+
+	// Sections holds the heading of the section each paragraph is in, "" for the lead. It is
+	// nil if the source doesn't tell sections apart.
+	Sections []string
+}
+
+// section returns the heading of the section paragraph i is in.
+func (a *Article) section(i int) string {
+	if i < len(a.Sections) {
+		return a.Sections[i]
+	}
+
+	return ""
+}
+
+// ExtractParagraphs tokenizes HTML as it is read and returns the text of each <p> tag,
+// before any punctuation is removed. The document is never held in memory as a whole.
+func ExtractParagraphs(r io.Reader) ([]string, error) {
+	article, err := ExtractArticle(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return article.Paragraphs, nil
+}
+
+// defaultExcludedClasses are the classes and ids of the page elements whose text is skipped:
+// reference markers, navigation boxes, hatnotes, infoboxes, edit links, rendered formulas,
+// chemical formulas and coordinates.
+var defaultExcludedClasses = []string{
+	"mw-ref", "reference", "navbox", "hatnote", "infobox", "mw-editsection",
+	"mwe-math-element", "chemf", "coordinates", "geo", "geo-default", "geo-nondefault",
+}
+
+// excludedTags are elements that never hold prose. MathML <math> elements carry the TeX
+// source of formulas, which would otherwise yield words like "displaystyle".
+var excludedTags = map[string]bool{"math": true}
+
+// excludedClasses are the classes and ids skipped in use, guarded by configMu.
+var excludedClasses = classSet(defaultExcludedClasses)
+
+// voidElements never have an end tag, so they hold no text to skip.
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true,
+	"input": true, "link": true, "meta": true, "source": true, "track": true, "wbr": true,
+}
+
+func classSet(classes []string) map[string]bool {
+	set := make(map[string]bool, len(classes))
+	for _, class := range classes {
+		set[class] = true
+	}
+
+	return set
+}
+
+// ExtractArticle tokenizes an article page as it is read, collecting the text of each <p> tag
+// with the <h2>-<h6> heading above it, the page heading (<h1 id="firstHeading">) as the
+// title, and the links listed in the visible category box (#mw-normal-catlinks) as the
+// categories. Elements with one of the excludedClasses as a class or id are skipped with
+// everything inside them.
+func ExtractArticle(r io.Reader) (*Article, error) {
+	configMu.RLock()
+	excluded := excludedClasses
+	configMu.RUnlock()
+
+	article := &Article{}
+	var builder, title, heading, category strings.Builder
+	depth := 0
+	inTitle, inHeading := false, false
+	inCategories, inCategory := false, false
+	section := ""
+
+	// skipTag is the name of the excluded element being skipped, and skipDepth counts the
+	// elements of that name open inside it, so its own end tag can be told apart.
+	var skipTag string
+	skipDepth := 0
+
+	z := html.NewTokenizer(r)
+	for {
+		token := z.Next()
+		if skipTag != "" {
+			name, _ := z.TagName()
+			switch {
+			case token == html.StartTagToken && string(name) == skipTag:
+				skipDepth++
+			case token == html.EndTagToken && string(name) == skipTag:
+				if skipDepth--; skipDepth == 0 {
+					skipTag = ""
+				}
+			case token == html.ErrorToken:
+				skipTag = ""
+			}
+			if token != html.ErrorToken {
+				continue
+			}
+		}
+
+		switch token {
+		case html.ErrorToken:
+			if errors.Is(z.Err(), io.EOF) {
+				article.Title = strings.Join(strings.Fields(removeInvisible(title.String())), " ")
+				return article, nil
+			}
+			return nil, fmt.Errorf("failed to parse HTML: %w", z.Err())
+
+		case html.StartTagToken:
+			name, hasAttr := z.TagName()
+			var class, id string
+			if hasAttr {
+				class, id = classAndID(z)
+			}
+			if (excludedTags[string(name)] || isExcluded(excluded, class, id)) && !voidElements[string(name)] {
+				skipTag, skipDepth = string(name), 1
+				continue
+			}
+
+			if id == "mw-normal-catlinks" {
+				inCategories = true
+			}
+
+			switch string(name) {
+			case "p":
+				depth++
+			case "li":
+				if inCategories {
+					inCategory = true
+					category.Reset()
+				}
+			case "h1":
+				inTitle = id == "firstHeading"
+			case "h2", "h3", "h4", "h5", "h6":
+				inHeading = true
+				heading.Reset()
+			}
+
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			switch string(name) {
+			case "p":
+				if depth > 0 {
+					depth--
+					if depth == 0 {
+						article.Paragraphs = append(article.Paragraphs, removeInvisible(builder.String()))
+						article.Sections = append(article.Sections, section)
+						builder.Reset()
+					}
+				}
+			case "li":
+				if inCategory {
+					inCategory = false
+					if name := strings.Join(strings.Fields(removeInvisible(category.String())), " "); name != "" {
+						article.Categories = append(article.Categories, name)
+					}
+				}
+			case "ul":
+				// The box holds a single list of categories.
+				inCategories = false
+			case "h1":
+				inTitle = false
+			case "h2", "h3", "h4", "h5", "h6":
+				if inHeading {
+					inHeading = false
+					section = strings.Join(strings.Fields(removeInvisible(heading.String())), " ")
+				}
+			}
+
+		case html.TextToken:
+			if depth > 0 {
+				builder.Write(z.Text())
+			}
+			if inTitle {
+				title.Write(z.Text())
+			}
+			if inHeading {
+				heading.Write(z.Text())
+			}
+			if inCategory {
+				category.Write(z.Text())
+			}
+		}
+	}
+}
+
+// invisibleCharacters are dropped from extracted text: soft hyphens, zero-width spaces and
+// joiners, and byte order marks. Non-breaking spaces become plain spaces.
+var invisibleCharacters = strings.NewReplacer(
+	"\u00AD", "", "\u200B", "", "\u200C", "", "\u200D", "", "\u2060", "", "\uFEFF", "",
+	"\u00A0", " ", "\u202F", " ",
+)
+
+// texPrefix starts the TeX source of a formula in plain text extracts.
+const texPrefix = "{\\displaystyle"
+
+// removeFormulas drops the {\displaystyle ...} formulas plain text extracts contain in place of
+// rendered math, braces included.
+func removeFormulas(s string) string {
+	for {
+		start := strings.Index(s, texPrefix)
+		if start < 0 {
+			return s
+		}
+
+		end, open := start, 0
+		for ; end < len(s); end++ {
+			if s[end] == '{' {
+				open++
+			} else if s[end] == '}' {
+				if open--; open == 0 {
+					break
+				}
+			}
+		}
+		s = s[:start] + s[min(end+1, len(s)):]
+	}
+}
+
+// cleanText decodes HTML entities left in plain text, such as the &shy; and &nbsp; of
+// wikitext in extracts and dumps, and drops invisible characters, which would otherwise end
+// up in contexts and excerpts or glue the letters of an entity name onto a word. Text from
+// the HTML tokenizer is decoded already and goes through removeInvisible only.
+func cleanText(s string) string {
+	return removeInvisible(html.UnescapeString(s))
+}
+
+// removeInvisible drops invisible characters from decoded text.
+func removeInvisible(s string) string {
+	return invisibleCharacters.Replace(s)
+}
+
+// classAndID returns the class and id attributes of the current tag. It consumes the tag's
+// attributes, so it can only be called once per token.
+func classAndID(z *html.Tokenizer) (class, id string) {
+	for {
+		key, value, more := z.TagAttr()
+		switch string(key) {
+		case "class":
+			class = string(value)
+		case "id":
+			id = string(value)
+		}
+		if !more {
+			return class, id
+		}
+	}
+}
+
+// isExcluded reports whether one of the classes or the id is in the excluded set.
+func isExcluded(excluded map[string]bool, class, id string) bool {
+	if excluded[id] {
+		return true
+	}
+	for _, name := range strings.Fields(class) {
+		if excluded[name] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// WordsFromParagraphs returns all words found in the paragraphs, cleaned of punctuation.
+func WordsFromParagraphs(paragraphs []string, language string) []string {
+	var words []string
+	for _, paragraph := range paragraphs {
+		words = append(words, strings.Fields(RemovePunctuation(paragraph, language))...)
+	}
+
+	return words
+}
+
+// RemovePunctuation removes all punctuation and special characters from a string,
+// keeping only letters, whitespace and apostrophes, and lowercases it by the rules of the
+// language.
+func RemovePunctuation(s string, language string) string {
+	return ToLowerLanguage(KeepLetters(s), language)
+}
+
+// KeepLetters removes all punctuation and special characters from a string, keeping only
+// letters, whitespace and apostrophes in their original case.
+func KeepLetters(s string) string {
+	var builder strings.Builder
+	for _, r := range s {
+		if isApostrophe(r) {
+			builder.WriteRune('\'')
+		} else if unicode.IsLetter(r) || unicode.IsSpace(r) {
+			builder.WriteRune(r)
+		}
+	}
+
+	return builder.String()
+}
+
+// isApostrophe reports whether r is an ASCII apostrophe or one of the characters typeset in
+// its place: the right single quotation mark (l’homme) and the modifier letter apostrophe.
+// They are all written as ASCII so each word is stored only once.
+func isApostrophe(r rune) bool {
+	return r == '\'' || r == '\u2019' || r == '\u02BC'
+}
+
+// normalizeApostrophes writes every apostrophe in s as ASCII.
+func normalizeApostrophes(s string) string {
+	return strings.Map(func(r rune) rune {
+		if isApostrophe(r) {
+			return '\''
+		}
+		return r
+	}, s)
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// Entities in the HTML are decoded once, so an escaped entity stays literal text.
+func TestExtractArticleDecodesEntitiesOnce(t *testing.T) {
+	article, err := ExtractArticle(strings.NewReader(`<p>a&amp;nbsp;b &amp;lt;c&amp;shy;d e&nbsp;f g&shy;h</p>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "a&nbsp;b &lt;c&shy;d e f gh"
+	if len(article.Paragraphs) != 1 || article.Paragraphs[0] != want {
+		t.Errorf("Paragraphs = %q, want %q", article.Paragraphs, want)
+	}
+}
+
+// Plain text extracts carry the raw entities of wikitext, which are decoded.
+func TestExtractParagraphsDecodesEntities(t *testing.T) {
+	paragraphs, _ := extractParagraphs("a&nbsp;b c&shy;d")
+
+	want := "a b cd"
+	if len(paragraphs) != 1 || paragraphs[0] != want {
+		t.Errorf("paragraphs = %q, want %q", paragraphs, want)
+	}
+}
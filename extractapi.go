@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// maxExtractBodySize bounds the HTML a client may send to /extract, which is several times the
+// size of a long Wikipedia article.
+const maxExtractBodySize = 8 << 20
+
+// ExtractRequest is the JSON body accepted by /extract: either HTML or the URL of a page on
+// one of the configured wikis or url_domains.
+type ExtractRequest struct {
+	HTML string `json:"html"`
+	URL  string `json:"url"`
+}
+
+// ExtractResponse is returned by /extract.
+type ExtractResponse struct {
+	Language   string   `json:"language"`
+	Title      string   `json:"title,omitempty"`
+	URL        string   `json:"url,omitempty"`
+	Paragraphs int      `json:"paragraphs"`
+	Words      []string `json:"words"`
+
+	// Debug describes the extraction, when `debug=true`.
+	Debug *Diagnostics `json:"debug,omitempty"`
+}
+
+// extractHandler runs the pick pipeline on content the client supplies instead of a random
+// article, returning every word it keeps in order. The body is HTML, or an ExtractRequest
+// when sent as application/json. The filter parameters of /pick, such as `safe`,
+// `starts_with` and `ngrams`, apply.
+func extractHandler(w http.ResponseWriter, r *http.Request) {
+	opts, err := parsePickOptions(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	body := http.MaxBytesReader(w, r.Body, maxExtractBodySize)
+	var article *Article
+	var fetched time.Duration
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "application/json" {
+		var request ExtractRequest
+		if err := json.NewDecoder(body).Decode(&request); err != nil {
+			writeError(w, invalidParameter("body", "invalid JSON body: %v", err))
+			return
+		}
+		switch {
+		case (request.HTML == "") == (request.URL == ""):
+			err = invalidParameter("body", "body must set one of html and url")
+		case request.URL != "":
+			start := time.Now()
+			article, err = fetchArticleAt(r.Context(), request.URL)
+			fetched = time.Since(start)
+		default:
+			article, err = extractBody(strings.NewReader(request.HTML))
+		}
+	} else {
+		article, err = extractBody(body)
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	pool, err := newCandidatePool(opts.Language, opts)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	pool.addArticle(article, fetched)
+	if pool.diagnostics != nil {
+		pool.diagnostics.Candidates = pool.distinct()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ExtractResponse{
+		Language:   opts.Language,
+		Title:      article.Title,
+		URL:        article.URL,
+		Paragraphs: len(article.Paragraphs),
+		Words:      append([]string{}, pool.Words...),
+		Debug:      pool.diagnostics,
+	})
+}
+
+// extractBody extracts the article from HTML sent by the client.
+func extractBody(r io.Reader) (*Article, error) {
+	article, err := ExtractArticle(r)
+	if err != nil {
+		return nil, invalidParameter("body", "unreadable body: %v", err)
+	}
+
+	return article, nil
+}
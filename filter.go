@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// wordFilter keeps only the candidate words matching a pick's starts_with, ends_with,
+// pattern and crossword parameters, and written in the language's script.
+type wordFilter struct {
+	prefix    string
+	suffix    string
+	pattern   *regexp.Regexp
+	crossword *regexp.Regexp
+	scripts   []*unicode.RangeTable
+}
+
+// compilePattern compiles a `pattern` parameter. A pattern between slashes is a regular
+// expression; anything else is a glob where `*` matches any run of letters and `?` one letter.
+// Either way the pattern must match the whole word.
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	if len(pattern) > 1 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+		return regexp.Compile("^(?:" + pattern[1:len(pattern)-1] + ")$")
+	}
+
+	glob := regexp.QuoteMeta(strings.ToLower(pattern))
+	glob = strings.ReplaceAll(glob, `\*`, ".*")
+	glob = strings.ReplaceAll(glob, `\?`, ".")
+
+	return regexp.Compile("^" + glob + "$")
+}
+
+// compileCrossword compiles a `crossword` parameter: either a word length, or the letters of
+// a word with `_` for each unknown one, such as `c__ss___d`. Crossword words are made of the
+// letters a to z only, without apostrophes or diacritics.
+func compileCrossword(crossword string) (*regexp.Regexp, error) {
+	if length, err := strconv.Atoi(crossword); err == nil {
+		if length < 1 {
+			return nil, errors.New("length must be positive")
+		}
+		return regexp.Compile(fmt.Sprintf("^[a-z]{%d}$", length))
+	}
+
+	var expr strings.Builder
+	expr.WriteString("^")
+	for _, r := range strings.ToLower(crossword) {
+		switch {
+		case r == '_':
+			expr.WriteString("[a-z]")
+		case r >= 'a' && r <= 'z':
+			expr.WriteRune(r)
+		default:
+			return nil, fmt.Errorf("unexpected %q; use a length or letters a-z and _", r)
+		}
+	}
+	expr.WriteString("$")
+
+	return regexp.Compile(expr.String())
+}
+
+// newWordFilter returns the filter for picking in the language with the options, or nil if
+// no words are filtered. The pattern, crossword and script have already been checked by
+// parsePickOptions. A requested script replaces the language's own.
+func newWordFilter(opts pickOptions, language string) *wordFilter {
+	var scripts []*unicode.RangeTable
+	switch {
+	case opts.Script != "":
+		scripts, _ = requestedScripts(opts.Script)
+	case !opts.ForeignScript:
+		scripts = languageScripts[language]
+	}
+	if opts.StartsWith == "" && opts.EndsWith == "" && opts.Pattern == "" && opts.Crossword == "" && scripts == nil {
+		return nil
+	}
+
+	filter := &wordFilter{prefix: opts.StartsWith, suffix: opts.EndsWith, scripts: scripts}
+	if opts.Pattern != "" {
+		filter.pattern, _ = compilePattern(opts.Pattern)
+	}
+	if opts.Crossword != "" {
+		filter.crossword, _ = compileCrossword(opts.Crossword)
+	}
+
+	return filter
+}
+
+// Apply returns the words that match the filter. A nil filter keeps every word.
+func (f *wordFilter) Apply(words []string) []string {
+	if f == nil {
+		return words
+	}
+
+	kept := make([]string, 0, len(words))
+	for _, word := range words {
+		if !strings.HasPrefix(word, f.prefix) || !strings.HasSuffix(word, f.suffix) {
+			continue
+		}
+		if f.pattern != nil && !f.pattern.MatchString(word) {
+			continue
+		}
+		if f.crossword != nil && !f.crossword.MatchString(word) {
+			continue
+		}
+		if f.scripts != nil && !inScripts(word, f.scripts) {
+			continue
+		}
+		kept = append(kept, word)
+	}
+
+	return kept
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// deepCheckTimeout bounds how long a deep readiness check waits for each edition.
+const deepCheckTimeout = 5 * time.Second
+
+// deepCheckTTL is how long the result of a deep check is reused. /readyz needs no
+// authentication, so it must not be a way to flood Wikimedia or take the fetch slots of picks.
+const deepCheckTTL = 30 * time.Second
+
+var (
+	deepCheckFlight singleflight.Group
+
+	deepCheckMu     sync.Mutex
+	deepCheckAt     time.Time
+	deepCheckResult map[string]UpstreamCheck
+)
+
+// ReadyResponse is returned by /readyz.
+type ReadyResponse struct {
+	Ready     bool                     `json:"ready"`
+	Database  string                   `json:"database"`
+	Upstreams map[string]UpstreamCheck `json:"upstreams,omitempty"`
+}
+
+// UpstreamCheck is the result of reaching one Wikipedia edition in a deep check.
+type UpstreamCheck struct {
+	Reachable bool   `json:"reachable"`
+	LatencyMS int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+}
+
+// readyHandler reports whether the server can serve picks: the database answers and, with
+// `deep=true`, at least one configured Wikipedia edition is reachable, as checked within the
+// last deepCheckTTL. It answers 503 when not ready.
+func readyHandler(w http.ResponseWriter, r *http.Request) {
+	response := ReadyResponse{Ready: true, Database: "ok"}
+	if err := db.PingContext(r.Context()); err != nil {
+		response.Ready = false
+		response.Database = err.Error()
+	}
+
+	if deep, _ := strconv.ParseBool(r.URL.Query().Get("deep")); deep {
+		response.Upstreams = cachedUpstreamChecks(r.Context())
+
+		reachable := false
+		for _, check := range response.Upstreams {
+			reachable = reachable || check.Reachable
+		}
+		response.Ready = response.Ready && reachable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if !response.Ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// cachedUpstreamChecks returns the last deep check if it is recent enough, or runs a new one
+// that concurrent requests share.
+func cachedUpstreamChecks(ctx context.Context) map[string]UpstreamCheck {
+	deepCheckMu.Lock()
+	if checks := deepCheckResult; checks != nil && time.Since(deepCheckAt) < deepCheckTTL {
+		deepCheckMu.Unlock()
+		return checks
+	}
+	deepCheckMu.Unlock()
+
+	checks, _, _ := deepCheckFlight.Do("", func() (any, error) {
+		// The check is shared, so it must not be cancelled when the first caller goes away.
+		checks := checkUpstreams(context.WithoutCancel(ctx))
+
+		deepCheckMu.Lock()
+		deepCheckResult, deepCheckAt = checks, time.Now()
+		deepCheckMu.Unlock()
+		return checks, nil
+	})
+
+	return checks.(map[string]UpstreamCheck)
+}
+
+// checkUpstreams asks every configured Wikipedia edition for its site name, a tiny response,
+// and times the round trip.
+func checkUpstreams(ctx context.Context) map[string]UpstreamCheck {
+	configMu.RLock()
+	languages := slices.Sorted(maps.Keys(randomArticleURLByLanguage))
+	configMu.RUnlock()
+
+	checks := make(map[string]UpstreamCheck, len(languages))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, language := range languages {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			start := time.Now()
+			err := pingUpstream(ctx, language)
+			check := UpstreamCheck{Reachable: err == nil, LatencyMS: time.Since(start).Milliseconds()}
+			if err != nil {
+				check.Error = err.Error()
+			}
+
+			mu.Lock()
+			checks[language] = check
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	return checks
+}
+
+func pingUpstream(ctx context.Context, language string) error {
+	ctx, cancel := context.WithTimeout(ctx, deepCheckTimeout)
+	defer cancel()
+
+	release, err := acquireFetchSlot(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	endpoint, err := wikimediaSource{project: "wikipedia"}.apiURL(language)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+"?action=query&meta=siteinfo&siprop=general&format=json", nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := upstreamClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("wikipedia returned %s", resp.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// HistoryEntry is a used word together with where and when it was picked.
+type HistoryEntry struct {
+	Word         string     `json:"word"`
+	Language     string     `json:"language"`
+	ArticleTitle string     `json:"article_title,omitempty"`
+	ArticleURL   string     `json:"article_url,omitempty"`
+	PickedAt     *time.Time `json:"picked_at,omitempty"`
+}
+
+// HistoryResponse is returned by /history.
+type HistoryResponse struct {
+	Entries []HistoryEntry `json:"entries"`
+}
+
+// getHistory returns the most recently picked words of the namespace, newest first. An empty
+// language returns words of every language. Only the user's words are returned, so an empty
+// user, as for API keys and anonymous requests, returns the words picked without one. Words
+// picked from from on and before to are returned; zero times leave that end open. Words
+// stored before provenance was recorded come last, and only when both ends are open.
+func getHistory(tx dbtx, namespace string, language string, user string, from, to time.Time, limit int) ([]HistoryEntry, error) {
+	var fromArg, toArg any
+	if !from.IsZero() {
+		fromArg = from.UTC()
+	}
+	if !to.IsZero() {
+		toArg = to.UTC()
+	}
+
+	rows, err := tx.Query(`SELECT word, language, article_title, article_url, picked_at FROM used_words
+		WHERE namespace=? AND (?='' OR language=?) AND COALESCE(picked_by, '')=?
+			AND (? IS NULL OR picked_at >= ?) AND (? IS NULL OR picked_at < ?)
+		ORDER BY picked_at IS NULL, picked_at DESC, word
+		LIMIT ?`, namespace, language, language, user, fromArg, fromArg, toArg, toArg, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []HistoryEntry{}
+	for rows.Next() {
+		var entry HistoryEntry
+		var title, url sql.NullString
+		var pickedAt sql.NullTime
+		if err := rows.Scan(&entry.Word, &entry.Language, &title, &url, &pickedAt); err != nil {
+			return nil, err
+		}
+		entry.ArticleTitle = title.String
+		entry.ArticleURL = url.String
+		if pickedAt.Valid {
+			entry.PickedAt = &pickedAt.Time
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+func historyHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	limit := 100
+	if value := query.Get("limit"); value != "" {
+		var err error
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 {
+			writeError(w, invalidParameter("limit", "limit must be a positive integer"))
+			return
+		}
+	}
+
+	var from, to time.Time
+	for name, value := range map[string]*time.Time{"from": &from, "to": &to} {
+		if text := query.Get(name); text != "" {
+			parsed, err := parseHistoryTime(text, name == "to")
+			if err != nil {
+				writeError(w, invalidParameter(name, "%s must be a date (2006-01-02) or an RFC 3339 time", name))
+				return
+			}
+			*value = parsed
+		}
+	}
+
+	// /languages/{code}/history gives the language in the path.
+	language := r.PathValue("code")
+	if language == "" {
+		language = query.Get("language")
+	}
+
+	entries, err := getHistory(db, requestNamespace(r.Context()), language, requestUser(r.Context()), from, to, limit)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(HistoryResponse{Entries: entries})
+}
+
+// UndoResponse lists the words of the pick undone by /history/undo.
+type UndoResponse struct {
+	Language string   `json:"language"`
+	Words    []string `json:"words"`
+}
+
+func initPickIndex() error {
+	_, err := db.Exec(`CREATE INDEX IF NOT EXISTS used_words_namespace_pick_id ON used_words(namespace, pick_id)`)
+	return err
+}
+
+// undoLastPick deletes the words of the namespace's most recent pick in the language from
+// used_words, so they can be picked again, and returns them. Only the user's picks are undone,
+// so an empty user undoes only picks made without one. Words stored before picks were
+// recorded can't be undone.
+func undoLastPick(namespace string, language string, user string) ([]string, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var pickID string
+	err = tx.QueryRow(`SELECT pick_id FROM used_words
+		WHERE namespace=? AND language=? AND COALESCE(picked_by, '')=? AND pick_id IS NOT NULL
+		ORDER BY picked_at DESC, rowid DESC
+		LIMIT 1`, namespace, language, user).Scan(&pickID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, &apiError{
+			Status:  http.StatusNotFound,
+			Code:    CodeNotFound,
+			Message: fmt.Sprintf("no pick to undo in language %s", language),
+			Details: map[string]any{"language": language},
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.Query("DELETE FROM used_words WHERE namespace=? AND pick_id=? RETURNING word", namespace, pickID)
+	if err != nil {
+		return nil, err
+	}
+	words := []string{}
+	for rows.Next() {
+		var word string
+		if err := rows.Scan(&word); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		words = append(words, word)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	slices.Sort(words)
+
+	return words, tx.Commit()
+}
+
+// undoHandler serves POST /history/undo, returning the most recent batch of words to the pool.
+func undoHandler(w http.ResponseWriter, r *http.Request) {
+	language := requestLanguage(r)
+	var words []string
+	err := retryBusy(r.Context(), func() (err error) {
+		words, err = undoLastPick(requestNamespace(r.Context()), language, requestUser(r.Context()))
+		return err
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(UndoResponse{Language: language, Words: words})
+}
+
+// parseHistoryTime reads a `from` or `to` parameter. A date is midnight UTC of that day; as the
+// end of a range it includes the whole day, so from=2024-09-02&to=2024-09-08 covers a week.
+func parseHistoryTime(text string, end bool) (time.Time, error) {
+	if date, err := time.Parse(time.DateOnly, text); err == nil {
+		if end {
+			date = date.AddDate(0, 0, 1)
+		}
+		return date, nil
+	}
+
+	return time.Parse(time.RFC3339, text)
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// idempotencyKeyHeader names the header a client sets to make retries of a pick safe.
+const idempotencyKeyHeader = "Idempotency-Key"
+
+// maxIdempotencyKeyLength bounds idempotency keys, which are stored with the response.
+const maxIdempotencyKeyLength = 255
+
+// idempotencyTTL is how long the response to a pick with an idempotency key is kept.
+const idempotencyTTL = 24 * time.Hour
+
+// idempotentFlight makes concurrent picks with the same namespace and key share one pick.
+var idempotentFlight singleflight.Group
+
+// A pick sent with an Idempotency-Key header is stored with its response. Sending the key
+// again, such as when a flaky connection dropped the first response, returns that response
+// instead of picking, and using up, another batch. Keys belong to a namespace and expire
+// after idempotencyTTL.
+func initIdempotencyTable() error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS idempotent_picks (namespace TEXT NOT NULL DEFAULT '',key TEXT,options TEXT,body BLOB,created_at DATETIME,PRIMARY KEY(namespace, key))`)
+	return err
+}
+
+// idempotentPick runs cachedPick, unless the key was already used with the same options, in
+// which case the stored response is returned. An empty key always picks.
+func idempotentPick(ctx context.Context, key string, opts pickOptions) (*pickResult, error) {
+	if key == "" {
+		return cachedPick(ctx, opts)
+	}
+	if len(key) > maxIdempotencyKeyLength {
+		return nil, invalidParameter(idempotencyKeyHeader, "%s must be at most %d characters", idempotencyKeyHeader, maxIdempotencyKeyLength)
+	}
+
+	options := opts.fingerprint()
+	result, err, _ := idempotentFlight.Do(opts.Namespace+"\x00"+key, func() (any, error) {
+		body, err := getIdempotentPick(db, opts.Namespace, key, options, time.Now().Add(-idempotencyTTL))
+		if err != nil {
+			return nil, err
+		}
+		if body != nil {
+			return replayedPick(body)
+		}
+
+		// Like a shared cached pick, this one must finish for every caller waiting on it.
+		result, err := cachedPick(context.WithoutCancel(ctx), opts)
+		if err != nil {
+			return nil, err
+		}
+		err = retryBusy(context.WithoutCancel(ctx), func() error {
+			return storeIdempotentPick(db, opts.Namespace, key, options, result.body)
+		})
+		if err != nil {
+			// The words are used up already, so the client gets them; only a retry with
+			// the key would pick again.
+			slog.ErrorContext(ctx, "Failed to store idempotent pick", "error", err)
+		}
+		return result, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result.(*pickResult), nil
+}
+
+// getIdempotentPick returns the response stored for the key since the time, or nil if there is
+// none. It fails if the key was used with other options.
+func getIdempotentPick(tx dbtx, namespace, key, options string, since time.Time) ([]byte, error) {
+	var stored string
+	var body []byte
+	err := tx.QueryRow("SELECT options, body FROM idempotent_picks WHERE namespace=? AND key=? AND created_at>=?", namespace, key, since.UTC()).
+		Scan(&stored, &body)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if stored != options {
+		return nil, invalidParameter(idempotencyKeyHeader, "%s was already used for a pick with other options", idempotencyKeyHeader)
+	}
+
+	return body, nil
+}
+
+// storeIdempotentPick stores the response of the pick made with the key, replacing an expired one.
+func storeIdempotentPick(tx dbtx, namespace, key, options string, body []byte) error {
+	_, err := tx.Exec("INSERT OR REPLACE INTO idempotent_picks(namespace,key,options,body,created_at) VALUES (?,?,?,?,?)",
+		namespace, key, options, body, time.Now().UTC())
+	return err
+}
+
+// pruneIdempotentPicks deletes the responses stored before the time.
+func pruneIdempotentPicks(tx dbtx, before time.Time) error {
+	_, err := tx.Exec("DELETE FROM idempotent_picks WHERE created_at<?", before.UTC())
+	return err
+}
+
+// replayedPick rebuilds the result of a stored response for the audit log.
+func replayedPick(body []byte) (*pickResult, error) {
+	var response Response
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, err
+	}
+
+	return &pickResult{
+		body:     body,
+		language: response.Language,
+		words:    response.Words,
+		articles: auditedArticles(response.Sources),
+		cached:   true,
+		replayed: true,
+	}, nil
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// jwtSecret signs the access and refresh tokens issued by /auth. Tokens are disabled when it
+// is empty.
+var jwtSecret string
+
+// accessTokenTTL and refreshTokenTTL are how long issued tokens stay valid, and
+// sessionLifetime how long refreshing can extend a session past its first token.
+var (
+	accessTokenTTL  = 15 * time.Minute
+	refreshTokenTTL = 30 * 24 * time.Hour
+	sessionLifetime = 90 * 24 * time.Hour
+)
+
+const (
+	accessTokenType  = "access"
+	refreshTokenType = "refresh"
+)
+
+var errInvalidToken = errors.New("invalid token")
+
+// jwtHeader is the encoded header of every token: HMAC-SHA256 signed JWTs.
+var jwtHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// tokenClaims are the claims of the tokens we issue. Type tells access tokens, which
+// authenticate API requests, from refresh tokens, which are only accepted by /auth/refresh.
+// AuthTime is when the session started, with an API key or a login, and is kept by refreshes.
+type tokenClaims struct {
+	Subject   string `json:"sub"`
+	Type      string `json:"typ"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+	AuthTime  int64  `json:"auth_time,omitempty"`
+}
+
+// TokenResponse is returned by /auth/token and /auth/refresh.
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+// RefreshRequest is the body accepted by /auth/refresh.
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+func signToken(claims tokenClaims) (string, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := jwtHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return unsigned + "." + tokenSignature(unsigned), nil
+}
+
+func tokenSignature(unsigned string) string {
+	mac := hmac.New(sha256.New, []byte(jwtSecret))
+	mac.Write([]byte(unsigned))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// parseToken verifies the token's signature, expiry and type and returns its claims.
+func parseToken(token string, tokenType string, now time.Time) (*tokenClaims, error) {
+	header, rest, found := strings.Cut(token, ".")
+	if !found || header != jwtHeader {
+		return nil, errInvalidToken
+	}
+	payload, signature, found := strings.Cut(rest, ".")
+	if !found || !hmac.Equal([]byte(signature), []byte(tokenSignature(header+"."+payload))) {
+		return nil, errInvalidToken
+	}
+
+	decoded, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, errInvalidToken
+	}
+	var claims tokenClaims
+	if err := json.Unmarshal(decoded, &claims); err != nil {
+		return nil, errInvalidToken
+	}
+	if claims.Type != tokenType || now.Unix() >= claims.ExpiresAt {
+		return nil, errInvalidToken
+	}
+
+	return &claims, nil
+}
+
+// issueTokens returns a new access and refresh token pair for the subject of a session that
+// started at authTime. The refresh token expires with the session at the latest.
+func issueTokens(subject string, authTime time.Time, now time.Time) (*TokenResponse, error) {
+	access, err := signToken(tokenClaims{
+		Subject:   subject,
+		Type:      accessTokenType,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(accessTokenTTL).Unix(),
+		AuthTime:  authTime.Unix(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	refresh, err := signToken(tokenClaims{
+		Subject:   subject,
+		Type:      refreshTokenType,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: min(now.Add(refreshTokenTTL).Unix(), authTime.Add(sessionLifetime).Unix()),
+		AuthTime:  authTime.Unix(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenResponse{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(accessTokenTTL.Seconds()),
+	}, nil
+}
+
+// apiKeySubject identifies the holder of an API key in the tokens issued for it, without
+// putting the key itself in the token.
+func apiKeySubject(key string) string {
+	if key == "" {
+		return "anonymous"
+	}
+	sum := sha256.Sum256([]byte(key))
+
+	return "key:" + hex.EncodeToString(sum[:6])
+}
+
+// bearerAccessToken returns the claims of the request's access token, or nil if it carries
+// no valid one.
+func bearerAccessToken(r *http.Request) *tokenClaims {
+	token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if jwtSecret == "" || !found {
+		return nil
+	}
+	claims, err := parseToken(token, accessTokenType, time.Now())
+	if err != nil {
+		return nil
+	}
+
+	return claims
+}
+
+type userKey struct{}
+
+// withUser records the subject of the request's access token as its user.
+func withUser(ctx context.Context, user string) context.Context {
+	return context.WithValue(ctx, userKey{}, user)
+}
+
+// requestUser returns the user a request authenticated as with an access token, or "" for
+// requests made with an API key or without authentication.
+func requestUser(ctx context.Context) string {
+	user, _ := ctx.Value(userKey{}).(string)
+	return user
+}
+
+// requireTokens hides the /auth endpoints unless a JWT secret is configured.
+func requireTokens(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if jwtSecret == "" {
+			notFoundHandler(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// tokenHandler exchanges an API key for a token pair. It is registered behind requireAPIKey.
+func tokenHandler(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	tokens, err := issueTokens(apiKeySubject(r.Header.Get("X-API-Key")), now, now)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tokens)
+}
+
+// refreshHandler exchanges a refresh token for a new token pair, unless its session is older
+// than sessionLifetime or was started with an API key that is no longer accepted.
+func refreshHandler(w http.ResponseWriter, r *http.Request) {
+	var body RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, invalidParameter("body", "invalid JSON body: %v", err))
+		return
+	}
+
+	now := time.Now()
+	claims, err := parseToken(body.RefreshToken, refreshTokenType, now)
+	if err == nil && !sessionValid(claims, now) {
+		err = errInvalidToken
+	}
+	if err != nil {
+		writeError(w, &apiError{
+			Status:  http.StatusUnauthorized,
+			Code:    CodeUnauthorized,
+			Message: "missing, invalid or expired refresh token",
+		})
+		return
+	}
+
+	tokens, err := issueTokens(claims.Subject, sessionStart(claims), now)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tokens)
+}
+
+// sessionStart is when the token's session started. Tokens issued before sessions were
+// tracked count from their own issue time.
+func sessionStart(claims *tokenClaims) time.Time {
+	if claims.AuthTime == 0 {
+		return time.Unix(claims.IssuedAt, 0)
+	}
+	return time.Unix(claims.AuthTime, 0)
+}
+
+// sessionValid reports whether the token's session may be extended: it is younger than
+// sessionLifetime, and a session started with an API key needs the key to still be accepted.
+func sessionValid(claims *tokenClaims, now time.Time) bool {
+	if !now.Before(sessionStart(claims).Add(sessionLifetime)) {
+		return false
+	}
+
+	switch {
+	case strings.HasPrefix(claims.Subject, "key:"):
+		return slices.ContainsFunc(apiKeys, func(key string) bool { return apiKeySubject(key) == claims.Subject })
+	case claims.Subject == apiKeySubject(""):
+		// Tokens were handed out without a key while none was required.
+		return len(apiKeys) == 0
+	}
+
+	return true
+}
@@ -0,0 +1,58 @@
+package main
+
+import "strings"
+
+// maxLanguageAttempts is how many random articles are tried before accepting one
+// whose text doesn't look like the requested language.
+const maxLanguageAttempts = 3
+
+// stopWordsByLanguage holds common function words used to guess the language of a text.
+var stopWordsByLanguage = map[string]map[string]struct{}{
+	"en": wordSet("the", "of", "and", "to", "in", "is", "was", "that", "for", "it", "with", "as", "on", "by", "at", "from", "his", "her", "which", "are", "were", "has", "have", "this", "an", "or", "be", "not", "they", "their"),
+	"fr": wordSet("le", "la", "les", "de", "des", "du", "et", "est", "un", "une", "dans", "en", "au", "aux", "pour", "par", "sur", "qui", "que", "avec", "il", "elle", "sont", "été", "son", "sa", "ses", "ce", "cette", "pas"),
+	"de": wordSet("der", "die", "das", "und", "ist", "in", "den", "von", "zu", "mit", "sich", "des", "auf", "für", "nicht", "ein", "eine", "einer", "dem", "als", "auch", "es", "an", "wurde", "im", "bei", "aus", "nach", "wird", "sie"),
+}
+
+func wordSet(words ...string) map[string]struct{} {
+	set := make(map[string]struct{}, len(words))
+	for _, word := range words {
+		set[word] = struct{}{}
+	}
+
+	return set
+}
+
+// DetectLanguage guesses the language of the paragraphs by counting stop words.
+// It returns the best matching language and the confidence that the text is in the
+// requested language. known is false when the requested language has no stop word list
+// or the text contains no recognised stop words.
+func DetectLanguage(paragraphs []string, requested string) (detected string, confidence float64, known bool) {
+	if _, found := stopWordsByLanguage[requested]; !found {
+		return "", 0, false
+	}
+
+	hits := make(map[string]int)
+	total := 0
+	for _, paragraph := range paragraphs {
+		for _, word := range strings.Fields(RemovePunctuation(paragraph, requested)) {
+			for language, stopWords := range stopWordsByLanguage {
+				if _, found := stopWords[word]; found {
+					hits[language]++
+					total++
+				}
+			}
+		}
+	}
+	if total == 0 {
+		return "", 0, false
+	}
+
+	detected = requested
+	for language, count := range hits {
+		if count > hits[detected] {
+			detected = language
+		}
+	}
+
+	return detected, float64(hits[requested]) / float64(total), true
+}
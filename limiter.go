@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// fetchSlots limits how many Wikipedia fetches run at once. It is sized in main.
+var fetchSlots chan struct{}
+
+// fetchQueueTimeout is how long a fetch waits for a free slot before the request is
+// rejected. Zero rejects immediately when all slots are taken.
+var fetchQueueTimeout time.Duration
+
+var errServerBusy = &apiError{
+	Status:  http.StatusServiceUnavailable,
+	Code:    CodeServerBusy,
+	Message: "too many concurrent requests, try again later",
+}
+
+// acquireFetchSlot blocks until a fetch slot is free, the queue timeout passes or ctx is done.
+// Callers must call the returned release function once the fetch has finished.
+func acquireFetchSlot(ctx context.Context) (release func(), err error) {
+	release = func() { <-fetchSlots }
+
+	select {
+	case fetchSlots <- struct{}{}:
+		return release, nil
+	default:
+	}
+	if fetchQueueTimeout <= 0 {
+		return nil, errServerBusy
+	}
+
+	timer := time.NewTimer(fetchQueueTimeout)
+	defer timer.Stop()
+
+	select {
+	case fetchSlots <- struct{}{}:
+		return release, nil
+	case <-timer.C:
+		return nil, errServerBusy
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
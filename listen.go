@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// systemdListenFDsStart is the first file descriptor systemd passes to an activated service.
+const systemdListenFDsStart = 3
+
+// shutdownTimeout is how long the server lets running requests finish when it is stopped.
+const shutdownTimeout = 30 * time.Second
+
+// systemdListeners returns the sockets systemd passed to the process with socket activation,
+// or none if it wasn't started that way. Under a .socket unit systemd keeps the sockets open
+// across restarts, so connections made while the service restarts wait instead of failing.
+func systemdListeners() ([]net.Listener, error) {
+	if os.Getenv("LISTEN_PID") != strconv.Itoa(os.Getpid()) {
+		return nil, nil
+	}
+	count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || count < 1 {
+		return nil, nil
+	}
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+
+	// The sockets are meant for this process only, not for any it starts.
+	for _, name := range []string{"LISTEN_PID", "LISTEN_FDS", "LISTEN_FDNAMES"} {
+		os.Unsetenv(name)
+	}
+
+	var listeners []net.Listener
+	for i := range count {
+		name := "LISTEN_FD_" + strconv.Itoa(systemdListenFDsStart+i)
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+
+		file := os.NewFile(uintptr(systemdListenFDsStart+i), name)
+		listener, err := net.FileListener(file)
+		file.Close()
+		if err != nil {
+			closeListeners(listeners)
+			return nil, fmt.Errorf("socket %s from systemd: %w", name, err)
+		}
+		listeners = append(listeners, listener)
+	}
+
+	return listeners, nil
+}
+
+// unixSocketPrefix marks a -listen address as the path of a Unix socket.
+const unixSocketPrefix = "unix:"
+
+// unixSocketMode is the file mode Unix sockets are created with, which decides the local
+// users, such as a reverse proxy's, that may connect.
+var unixSocketMode os.FileMode = 0o660
+
+// listen opens a listener for each of the comma-separated addresses: TCP addresses such as
+// "127.0.0.1:8080" or "[::1]:8080", or Unix sockets such as "unix:/run/wwp/wwp.sock". A TCP
+// address without a host, like ":8080", listens on every interface over both IPv4 and IPv6.
+func listen(addresses string) ([]net.Listener, error) {
+	var listeners []net.Listener
+	for _, address := range strings.Split(addresses, ",") {
+		if address = strings.TrimSpace(address); address == "" {
+			continue
+		}
+
+		var listener net.Listener
+		var err error
+		if path, found := strings.CutPrefix(address, unixSocketPrefix); found {
+			listener, err = listenUnix(path)
+		} else if _, _, err = net.SplitHostPort(address); err != nil {
+			err = fmt.Errorf("invalid address %q: %w", address, err)
+		} else {
+			listener, err = net.Listen("tcp", address)
+		}
+		if err != nil {
+			closeListeners(listeners)
+			return nil, err
+		}
+		listeners = append(listeners, listener)
+	}
+	if len(listeners) == 0 {
+		return nil, fmt.Errorf("no address to listen on")
+	}
+
+	return listeners, nil
+}
+
+// parseSocketMode reads a -socket-mode such as "0660".
+func parseSocketMode(text string) (os.FileMode, error) {
+	mode, err := strconv.ParseUint(text, 8, 32)
+	if err != nil || mode > 0o777 {
+		return 0, fmt.Errorf("%q is not an octal file mode", text)
+	}
+
+	return os.FileMode(mode), nil
+}
+
+// listenUnix listens on a Unix socket at the path, replacing a socket left behind by a server
+// that didn't shut down cleanly. The socket is removed again when the listener is closed.
+func listenUnix(path string) (net.Listener, error) {
+	if info, err := os.Stat(path); err == nil {
+		if info.Mode().Type() != os.ModeSocket {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("%s is in use by another server", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, unixSocketMode); err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	return listener, nil
+}
+
+func closeListeners(listeners []net.Listener) {
+	for _, listener := range listeners {
+		listener.Close()
+	}
+}
+
+// serve serves the handler on every listener, returning when any of them fails. On SIGINT or
+// SIGTERM it stops accepting connections and returns nil once running requests have finished,
+// or after shutdownTimeout.
+func serve(listeners []net.Listener, handler http.Handler) error {
+	server := &http.Server{Handler: handler}
+
+	errs := make(chan error, len(listeners))
+	for _, listener := range listeners {
+		slog.Info("Listening", "addr", listener.Addr().String())
+		go func() { errs <- server.Serve(listener) }()
+	}
+
+	stop, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	select {
+	case err := <-errs:
+		return err
+	case <-stop.Done():
+	}
+
+	slog.Info("Shutting down")
+	ctx, cancelShutdown := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancelShutdown()
+
+	return server.Shutdown(ctx)
+}
@@ -1,236 +1,297 @@
 package main
 
 import (
-	"database/sql"
-	"encoding/json"
-	"fmt"
-	"io"
-	"log"
+	"context"
+	"flag"
+	"log/slog"
 	"math/rand"
-	"net/http"
-	"strconv"
 	"strings"
-	"unicode"
-
-	"golang.org/x/net/html"
-	_ "modernc.org/sqlite"
+	"time"
+	"unicode/utf8"
 )
 
-var randomArticleURLByLanguage = map[string]string{
-	"en": "https://en.wikipedia.org/wiki/Special:Random",
-	"fr": "https://fr.wikipedia.org/wiki/Sp%C3%A9cial:Page_au_hasard",
-	"de": "https://de.wikipedia.org/wiki/Spezial:Zuf%C3%A4llige_Seite",
-}
-
 type Response struct {
+	APIVersion string `json:"api_version"`
+
 	Language string   `json:"language"`
 	Words    []string `json:"words"`
-}
+	Stats    *Stats   `json:"stats,omitempty"`
 
-var db *sql.DB
+	// Requested is the `count` asked for and Delivered how many words were picked. Exhausted
+	// is set when the articles had fewer unused words than requested.
+	Requested int  `json:"requested"`
+	Delivered int  `json:"delivered"`
+	Exhausted bool `json:"exhausted"`
 
-func initDB() error {
-	var err error
-	db, err = sql.Open("sqlite", "words.db")
-	if err != nil {
-		return err
-	}
-	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS used_words (word TEXT,language TEXT,PRIMARY KEY(word, language))`)
-	return err
-}
+	// Warnings report conditions that didn't stop the pick, such as a fallback language or a
+	// stub article.
+	Warnings []Warning `json:"warnings,omitempty"`
 
-func storeUsedWords(words []string, language string) error {
-	tx, err := db.Begin()
-	if err != nil {
-		return err
-	}
+	// Cursor is the token to pass as `cursor` for the next batch, when the pick used one.
+	Cursor string `json:"cursor,omitempty"`
 
-	stmt, err := tx.Prepare("INSERT OR IGNORE INTO used_words(word,language) VALUES (?,?)")
-	if err != nil {
-		return err
-	}
-	defer stmt.Close()
+	// Peek is set when the words weren't marked as used, because the pick set `peek=true`.
+	Peek bool `json:"peek,omitempty"`
 
-	for _, word := range words {
-		if _, err := stmt.Exec(word, language); err != nil {
-			tx.Rollback()
-			return err
-		}
-	}
+	// Debug describes how the words were extracted, when `debug=true`.
+	Debug *Diagnostics `json:"debug,omitempty"`
 
-	return tx.Commit()
-}
+	// Meta breaks down how long the pick took, when `meta=true`.
+	Meta *Meta `json:"meta,omitempty"`
 
-func getUsedWords(language string) (map[string]struct{}, error) {
-	rows, err := db.Query("SELECT word FROM used_words WHERE language=?", language)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
+	// Sources are the articles the words were picked from, with the address each random page
+	// redirected to.
+	Sources []Source `json:"sources,omitempty"`
 
-	used := make(map[string]struct{})
-	for rows.Next() {
-		var word string
-		if err := rows.Scan(&word); err != nil {
-			return nil, err
-		}
-		used[word] = struct{}{}
-	}
-	return used, nil
-}
+	// ArticleCount is how many articles the words were picked from, including any fetched
+	// because the first ones had too few unused words.
+	ArticleCount int `json:"article_count"`
 
-// ExtractWordsFromParagraphs parses HTML content, extracts text from <p> tags,
-// and returns a slice of all words found within those paragraphs.
-func ExtractWordsFromParagraphs(htmlContent string) ([]string, error) {
-	doc, err := html.Parse(strings.NewReader(htmlContent))
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse HTML: %w", err)
-	}
+	// Contexts maps each picked word to the sentence it appeared in, when `context=true`.
+	Contexts map[string]string `json:"contexts,omitempty"`
 
-	var words []string
+	// Excerpt is the first paragraph of the source article, when `excerpt=true`.
+	Excerpt string `json:"excerpt,omitempty"`
 
-	var traverse func(*html.Node)
-	traverse = func(n *html.Node) {
-		if n.Type == html.ElementNode && n.Data == "p" {
-			text := RemovePunctuation(getText(n))
-			words = append(words, strings.Fields(text)...)
-		}
-		for c := n.FirstChild; c != nil; c = c.NextSibling {
-			traverse(c)
-		}
-	}
-	traverse(doc)
+	// Difficulty rates each picked word from 1 (common) to 5 (rare), when `difficulty=true`.
+	Difficulty map[string]int `json:"difficulty,omitempty"`
+
+	// Scrabble is the Scrabble score of each picked word, when `scrabble=true`.
+	Scrabble map[string]int `json:"scrabble,omitempty"`
+
+	// Syllables is the estimated syllable count of each picked word, when `syllables=true`.
+	Syllables map[string]int `json:"syllables,omitempty"`
+
+	// Occurrences counts how often each picked word occurs in the source articles, when
+	// `occurrences=true`.
+	Occurrences map[string]int `json:"occurrences,omitempty"`
+
+	// Audio lists recordings of each picked word's pronunciation from Wiktionary, when
+	// `audio=true`. Words without recordings are left out.
+	Audio map[string][]string `json:"audio,omitempty"`
 
-	return words, nil
+	// Categories lists the categories of the article each picked word was first found in,
+	// when `categories=true`. Words from articles without categories are left out.
+	Categories map[string][]string `json:"categories,omitempty"`
+
+	// Sections groups the picked words under the heading of the article section they were
+	// found in, when `group_by=section`.
+	Sections []SectionWords `json:"sections,omitempty"`
+
+	// LanguageConfidence is the share of recognised function words that belong to the
+	// requested language. It is omitted for languages without a detection profile.
+	LanguageConfidence *float64 `json:"language_confidence,omitempty"`
+
+	// RequestedLanguage is set when the words come from a fallback language.
+	RequestedLanguage string `json:"requested_language,omitempty"`
 }
 
-// getText recursively retrieves all text content within a node.
-func getText(n *html.Node) string {
-	var builder strings.Builder
-	if n.Type == html.TextNode {
-		builder.WriteString(n.Data)
-	}
+// SectionWords are the picked words found in one section of an article. The section is "" for
+// the lead and for sources without sections.
+type SectionWords struct {
+	Article string   `json:"article"`
+	Section string   `json:"section"`
+	Words   []string `json:"words"`
+}
 
-	for c := n.FirstChild; c != nil; c = c.NextSibling {
-		builder.WriteString(getText(c))
-	}
+// Source identifies an article words were picked from. Articles from a dump have no URL.
+type Source struct {
+	Title string `json:"title,omitempty"`
+	URL   string `json:"url,omitempty"`
 
-	return builder.String()
+	// Image is the article's lead image, from the MediaWiki PageImages extension.
+	Image string `json:"image,omitempty"`
 }
 
-// RemovePunctuation removes all punctuation and special characters from a string,
-// keeping only letters, whitespace and apostrophes.
-func RemovePunctuation(s string) string {
-	var builder strings.Builder
-	for _, r := range s {
-		if unicode.IsLetter(r) || unicode.IsSpace(r) || r == '\'' {
-			builder.WriteRune(unicode.ToLower(r))
-		}
+// Stats describes the words extracted from an article, returned when `stats=true`.
+type Stats struct {
+	TotalWords         int         `json:"total_words"`
+	UniqueWords        int         `json:"unique_words"`
+	AlreadyUsed        int         `json:"already_used"`
+	LengthDistribution map[int]int `json:"length_distribution"`
+}
+
+// computeStats counts the words extracted from the articles before any filter, how many
+// distinct words were already used, and how many distinct words there are of each length.
+// Words are told apart by wordKey.
+func computeStats(words []string, usedBefore map[string]struct{}) *Stats {
+	stats := &Stats{
+		TotalWords:         len(words),
+		LengthDistribution: make(map[int]int),
 	}
 
-	return builder.String()
-}
+	seen := make(map[string]struct{})
+	for _, word := range words {
+		key := wordKey(word)
+		if _, found := seen[key]; found {
+			continue
+		}
+		seen[key] = struct{}{}
 
-// Check if a word is in an array.
-func contains(words []string, word string) bool {
-	for _, value := range words {
-		if value == word {
-			return true
+		stats.UniqueWords++
+		stats.LengthDistribution[utf8.RuneCountInString(word)]++
+		if _, used := usedBefore[key]; used {
+			stats.AlreadyUsed++
 		}
 	}
 
-	return false
+	return stats
 }
 
-// PickRandomUniqueWords returns n unique random words from the input slice.
-// If n > len(words), it returns all words.
-func PickRandomUniqueWords(words []string, n int, usedBefore map[string]struct{}) []string {
-	if n >= len(words) {
-		return words
-	}
+// maxCount is the largest `count` a pick request may ask for.
+var maxCount int
 
-	randomWords := make([]string, 0, n)
+// safeByDefault decides whether offensive words are removed when a request doesn't set `safe`.
+var safeByDefault bool
 
-	for {
-		word := words[rand.Intn(len(words))]
-		if _, used := usedBefore[word]; used || contains(randomWords, word) {
+// PickRandomUniqueWords returns n distinct random words from the input slice whose keys are
+// not in usedBefore. If there are fewer such words, it returns all of them. Words are told
+// apart by their wordKey.
+func PickRandomUniqueWords(words []string, n int, usedBefore map[string]struct{}) []string {
+	candidates := []string{}
+	seen := make(map[string]struct{})
+	for _, word := range words {
+		key := wordKey(word)
+		if _, used := usedBefore[key]; used {
 			continue
 		}
-
-		randomWords = append(randomWords, word)
-
-		if len(randomWords) == n {
-			break
+		if _, found := seen[key]; !found {
+			seen[key] = struct{}{}
+			candidates = append(candidates, word)
 		}
 	}
 
-	return randomWords
+	rand.Shuffle(len(candidates), func(i, j int) {
+		candidates[i], candidates[j] = candidates[j], candidates[i]
+	})
+
+	return candidates[:min(n, len(candidates))]
 }
 
-func pickHandler(w http.ResponseWriter, r *http.Request) {
-	language := r.URL.Query().Get("language")
-	if language == "" {
-		language = "en"
+func main() {
+	flag.IntVar(&maxCount, "max-count", 100, "largest number of words a single pick may request")
+	flag.BoolVar(&safeByDefault, "safe", false, "remove offensive words unless a request sets safe=false")
+	maxFetches := flag.Int("max-fetches", 8, "largest number of Wikipedia fetches running at once")
+	flag.DurationVar(&fetchQueueTimeout, "fetch-queue-timeout", 5*time.Second, "how long a pick waits for a free fetch slot before failing with 503")
+	flag.DurationVar(&maxTimeout, "max-timeout", 30*time.Second, "largest timeout_ms a single pick may set")
+	flag.IntVar(&maxArticles, "max-articles", 5, "largest number of articles a single pick may request")
+	flag.IntVar(&maxRedirects, "max-redirects", maxRedirects, "redirects a request to Wikipedia may follow before it fails")
+	flag.IntVar(&maxPoolArticles, "max-pool-articles", 10, "articles a pick may fetch in all when the requested ones have too few unused words")
+	flag.DurationVar(&cacheTTL, "cache-ttl", 0, "reuse pick responses for identical requests for this long; 0 disables the cache")
+	flag.DurationVar(&maintenanceInterval, "maintenance-interval", 24*time.Hour, "how often the database is checked for corruption, analyzed and vacuumed; 0 disables maintenance")
+	flag.BoolVar(&walMode, "wal", false, "open the database in write-ahead log mode, as replication tools such as Litestream require")
+	flag.StringVar(&replicaPath, "replica", "", "keep a copy of the database at this path, such as on another disk or a network mount; disabled when empty")
+	flag.DurationVar(&replicaInterval, "replica-interval", time.Minute, "how often the copy given to -replica is refreshed; 0 refreshes it only on start and shutdown")
+	flag.StringVar(&adminToken, "admin-token", "", "bearer token for the /admin endpoints; they are disabled when empty")
+	keys := flag.String("api-keys", "", "comma-separated keys accepted in the X-API-Key header; no key is needed when empty")
+	flag.StringVar(&jwtSecret, "jwt-secret", "", "secret signing the tokens issued by /auth/token; token auth is disabled when empty")
+	flag.DurationVar(&accessTokenTTL, "access-token-ttl", accessTokenTTL, "how long access tokens stay valid")
+	flag.DurationVar(&refreshTokenTTL, "refresh-token-ttl", refreshTokenTTL, "how long refresh tokens stay valid")
+	flag.DurationVar(&sessionLifetime, "session-lifetime", sessionLifetime, "how long refreshing can keep a token session going after it started")
+	flag.StringVar(&oauthClientID, "oauth-client-id", "", "client ID of the Wikimedia OAuth 2.0 consumer; login is disabled when empty")
+	flag.StringVar(&oauthClientSecret, "oauth-client-secret", "", "client secret of the Wikimedia OAuth 2.0 consumer")
+	flag.StringVar(&oauthRedirectURL, "oauth-redirect-url", "", "callback URL registered for the consumer, ending in /auth/wikimedia/callback")
+	flag.IntVar(&rateLimitPerMinute, "rate-limit", 0, "requests a client IP may make per minute; 0 disables the limit")
+	listenAddrs := flag.String("listen", ":8080", "comma-separated addresses to serve on, such as 127.0.0.1:8080,[::1]:8080 or unix:/run/wwp/wwp.sock; a bare :port listens on every interface over IPv4 and IPv6")
+	socketMode := flag.String("socket-mode", "0660", "octal permissions of the Unix sockets given to -listen")
+	debugAddr := flag.String("debug-listen", "", "serve pprof on this address (e.g. 127.0.0.1:6060), guarded by -admin-token; disabled when empty")
+	flag.IntVar(&breakerThreshold, "breaker-threshold", breakerThreshold, "upstream failures in a row that pause fetches from a Wikipedia edition; 0 never pauses")
+	flag.DurationVar(&breakerCooldown, "breaker-cooldown", breakerCooldown, "how long fetches from a failing edition are paused before one is tried again")
+	flag.BoolVar(&corpusFallback, "corpus-fallback", false, "pick from the embedded word lists when Wikipedia can't be reached")
+	flag.BoolVar(&useExtracts, "extracts", useExtracts, "fetch Wikipedia articles as plain text extracts from the API instead of parsing HTML")
+	flag.Var(zimFlag{}, "zim", "serve source=zim picks for a language from a ZIM archive, as language=path; repeatable")
+	flag.StringVar(&defaultWordSource, "source", defaultWordSource, "word source used when a pick doesn't set source; use dump to serve offline")
+	importDumpPath := flag.String("import-dump", "", "import a pages-articles XML dump or WikiExtractor output (optionally .bz2) and exit")
+	dumpLanguage := flag.String("dump-language", "en", "language of the dump given to -import-dump")
+	prefetch := flag.String("prefetch", "", "comma-separated languages whose articles are fetched ahead of picks from the default source; serves /pick/longpoll")
+	flag.IntVar(&prefetchDepth, "prefetch-depth", 2, "articles kept ready per prefetched language")
+	outboundProxy := flag.String("outbound-proxy", "", "proxy URL for requests to Wikipedia and other upstreams, overriding HTTP_PROXY and HTTPS_PROXY")
+	configPath := flag.String("config", "", "JSON file overriding source URLs, such as random article addresses per language")
+	flag.Parse()
+
+	if err := setupLogging(); err != nil {
+		fatal("Invalid logging configuration", err)
 	}
 
-	count := r.URL.Query().Get("count")
-	if count == "" {
-		count = "10"
+	config := &Config{}
+	if *configPath != "" {
+		var err error
+		if config, err = loadConfig(*configPath); err != nil {
+			fatal("Invalid config file", err)
+		}
+		go reloadConfigOnHangup(*configPath)
 	}
+	config.apply()
 
-	countValue, err := strconv.Atoi(count)
-	if err != nil {
-		countValue = 10
+	if *outboundProxy != "" {
+		if err := setOutboundProxy(*outboundProxy); err != nil {
+			fatal("Invalid -outbound-proxy", err)
+		}
 	}
 
-	resp, err := http.Get(randomArticleURLByLanguage[language])
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+	fetchSlots = make(chan struct{}, *maxFetches)
+	for _, key := range strings.Split(*keys, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			apiKeys = append(apiKeys, key)
+		}
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
-	builder := new(strings.Builder)
-	_, err = builder.Write(body)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if *importDumpPath != "" {
+		if err := initDB(); err != nil {
+			fatal("Failed to open database", err)
+		}
+		imported, err := importDump(*importDumpPath, *dumpLanguage)
+		if err != nil {
+			fatal("Failed to import dump", err)
+		}
+		slog.Info("Imported dump", "articles", imported, "path", *importDumpPath)
 		return
 	}
 
-	words, err := ExtractWordsFromParagraphs(builder.String())
+	shutdownTracing, err := setupTracing(context.Background())
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		fatal("Failed to set up tracing", err)
 	}
+	defer shutdownTracing(context.Background())
 
-	usedBefore, err := getUsedWords(language)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+	if err := initDB(); err != nil {
+		fatal("Failed to open database", err)
 	}
 
-	firstNWords := PickRandomUniqueWords(words, countValue, usedBefore)
-
-	err = storeUsedWords(firstNWords, language)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+	if *prefetch != "" && prefetchDepth > 0 {
+		if err := startPrefetching(*prefetch); err != nil {
+			fatal("Invalid -prefetch", err)
+		}
 	}
-
-	response := Response{
-		Language: language,
-		Words:    firstNWords,
+	if maintenanceInterval > 0 {
+		go maintainDatabase(maintenanceInterval)
+	}
+	if replicaPath != "" {
+		logReplication(runReplication(context.Background()))
+		if replicaInterval > 0 {
+			go replicateDatabase(replicaInterval)
+		}
+	}
+	if *debugAddr != "" {
+		go serveDebug(*debugAddr)
 	}
-	//fmt.Println(words)
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
-}
 
-func main() {
-	initDB()
-	http.HandleFunc("/pick", pickHandler)
+	if unixSocketMode, err = parseSocketMode(*socketMode); err != nil {
+		fatal("Invalid -socket-mode", err)
+	}
 
-	log.Print("Listening on port: 8080")
-	log.Fatal(http.ListenAndServe(":8080", nil))
+	// Sockets passed by systemd socket activation take the place of -listen.
+	listeners, err := systemdListeners()
+	if err == nil && listeners == nil {
+		listeners, err = listen(*listenAddrs)
+	}
+	if err != nil {
+		fatal("Failed to listen", err)
+	}
+	if err := serve(listeners, newHandler()); err != nil {
+		fatal("Server stopped", err)
+	}
+	if replicaPath != "" {
+		logReplication(runReplication(context.Background()))
+	}
 }
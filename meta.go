@@ -0,0 +1,47 @@
+package main
+
+import "time"
+
+// Meta describes how long a pick took and why, returned when `meta=true`.
+type Meta struct {
+	// ArticlesFetched counts the articles fetched, including those fetched again for being in
+	// another language and those fetched to top up the pool.
+	ArticlesFetched int `json:"articles_fetched"`
+
+	// TimingUS is how long each stage of the pick took, in microseconds.
+	TimingUS Timing `json:"timing_us"`
+}
+
+// Timing breaks a pick down into stages. Fetch is the wall time of the fetches, which run
+// concurrently and include parsing the article's HTML; parse is splitting the text into
+// words; filter is the safe, word list and pattern filters; store is the word database:
+// reading and marking the used words and counting the articles' words for /analytics/top.
+// Total is the whole pick, including stages not listed, such as lookups for `audio=true`.
+type Timing struct {
+	Fetch  int64 `json:"fetch"`
+	Parse  int64 `json:"parse"`
+	Filter int64 `json:"filter"`
+	Sample int64 `json:"sample"`
+	Store  int64 `json:"store"`
+	Total  int64 `json:"total"`
+}
+
+// stageTimes accumulates the time a pool spent in each stage.
+type stageTimes struct {
+	fetch, parse, filter, sample, store time.Duration
+}
+
+// meta returns the Meta of a pick from the pool that started at start.
+func (p *candidatePool) meta(start time.Time) *Meta {
+	return &Meta{
+		ArticlesFetched: p.fetches,
+		TimingUS: Timing{
+			Fetch:  p.timing.fetch.Microseconds(),
+			Parse:  p.timing.parse.Microseconds(),
+			Filter: p.timing.filter.Microseconds(),
+			Sample: p.timing.sample.Microseconds(),
+			Store:  p.timing.store.Microseconds(),
+			Total:  time.Since(start).Microseconds(),
+		},
+	}
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Middleware wraps a handler with behaviour that runs around it.
+type Middleware func(http.Handler) http.Handler
+
+// Chain wraps h with the middleware. The first middleware is the outermost, so it runs first.
+func Chain(h http.Handler, middleware ...Middleware) http.Handler {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		h = middleware[i](h)
+	}
+
+	return h
+}
+
+type requestIDKey struct{}
+
+// requestID returns the ID assigned to the request by assignRequestID, or "" outside a request.
+func requestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// assignRequestID gives every request an ID, reusing a well-formed inbound X-Request-ID so a
+// request can be followed across proxies. The ID is echoed in the X-Request-ID response header,
+// where writeError picks it up, and logged with every record made in the request's context.
+func assignRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+
+		w.Header().Set("X-Request-ID", id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+// validRequestID accepts inbound IDs of up to 128 visible ASCII characters, so a client can't
+// inject anything odd into logs and headers.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+
+	return true
+}
+
+func newRequestID() string {
+	var id [16]byte
+	rand.Read(id[:])
+	return hex.EncodeToString(id[:])
+}
+
+// recoverPanics turns a panicking handler into a 500 JSON error instead of a dropped connection.
+func recoverPanics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				if recovered == http.ErrAbortHandler {
+					panic(recovered)
+				}
+				slog.ErrorContext(r.Context(), "Panic serving request", "method", r.Method, "path", r.URL.Path, "panic", recovered, "stack", string(debug.Stack()))
+				writeError(w, fmt.Errorf("internal error"))
+			}
+		}()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// statusRecorder remembers the status code written through it.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// logRequests logs the method, path, status and duration of every request.
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		next.ServeHTTP(recorder, r)
+
+		slog.InfoContext(r.Context(), "Request", "method", r.Method, "uri", r.URL.RequestURI(), "status", recorder.status, "duration", time.Since(start))
+	})
+}
+
+// rateLimit rejects requests from clients that have used up their allowance in the limiter.
+func rateLimit(limiter *clientLimiter) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !limiter.allow(clientIP(r), time.Now()) {
+				writeError(w, &apiError{
+					Status:  http.StatusTooManyRequests,
+					Code:    CodeRateLimited,
+					Message: "too many requests, try again later",
+				})
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// clientLimiter keeps a token bucket per client.
+type clientLimiter struct {
+	mu      sync.Mutex
+	rate    float64 // tokens per nanosecond
+	burst   float64
+	buckets map[string]*tokenBucket
+	swept   time.Time
+}
+
+// requestLimiter limits the requests of each client IP to rateLimitPerMinute, or the config
+// file's rate_limit.
+var requestLimiter = &clientLimiter{buckets: make(map[string]*tokenBucket)}
+
+// setLimit allows each client perMinute requests a minute, with bursts up to the same amount.
+// Zero disables the limit. Clients start over with a full allowance.
+func (l *clientLimiter) setLimit(perMinute int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.rate = float64(perMinute) / float64(time.Minute)
+	l.burst = float64(perMinute)
+	clear(l.buckets)
+}
+
+func (l *clientLimiter) allow(client string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.burst <= 0 {
+		return true
+	}
+
+	// Buckets that have refilled completely are the same as new ones, so drop them now and
+	// then to keep the map from growing with every client ever seen.
+	if now.Sub(l.swept) > time.Minute {
+		for key, bucket := range l.buckets {
+			if bucket.tokens+float64(now.Sub(bucket.last))*l.rate >= l.burst {
+				delete(l.buckets, key)
+			}
+		}
+		l.swept = now
+	}
+
+	bucket, found := l.buckets[client]
+	if !found {
+		bucket = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[client] = bucket
+	}
+
+	bucket.tokens = min(l.burst, bucket.tokens+float64(now.Sub(bucket.last))*l.rate)
+	bucket.last = now
+	if bucket.tokens < 1 {
+		return false
+	}
+
+	bucket.tokens--
+	return true
+}
+
+// clientIP returns the IP address the request came from.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// apiKeys are the keys accepted in the X-API-Key header. Requests need no key when it is empty.
+var apiKeys []string
+
+// requireAPIKey rejects requests without a valid X-API-Key header when keys are configured.
+// A bearer access token issued by /auth/token is accepted instead of the key.
+func requireAPIKey(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if claims := bearerAccessToken(r); claims != nil {
+			next.ServeHTTP(w, r.WithContext(withUser(r.Context(), claims.Subject)))
+			return
+		}
+		if len(apiKeys) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key := r.Header.Get("X-API-Key")
+		for _, valid := range apiKeys {
+			if subtle.ConstantTimeCompare([]byte(key), []byte(valid)) == 1 {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		writeError(w, &apiError{
+			Status:  http.StatusUnauthorized,
+			Code:    CodeUnauthorized,
+			Message: "missing or invalid API key or access token",
+		})
+	})
+}
+
+// requireAdmin checks the bearer token on admin requests. Admin endpoints don't exist
+// unless an admin token is configured.
+func requireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if adminToken == "" {
+			notFoundHandler(w, r)
+			return
+		}
+
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) != 1 {
+			writeError(w, &apiError{
+				Status:  http.StatusUnauthorized,
+				Code:    CodeUnauthorized,
+				Message: "missing or invalid admin token",
+			})
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
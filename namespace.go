@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// Namespaces let one deployment serve independent apps or classrooms: used words, word lists
+// and stats are kept apart per namespace. A request names its namespace with a /ns/{name}
+// path prefix or the X-Namespace header; without either it is in the default namespace, "".
+const (
+	namespaceHeader = "X-Namespace"
+	namespacePrefix = "/ns/"
+)
+
+// maxNamespaceLength bounds namespace names, which are stored with every used word.
+const maxNamespaceLength = 64
+
+type namespaceKey struct{}
+
+// requestNamespace returns the namespace assigned to the request by assignNamespace.
+func requestNamespace(ctx context.Context) string {
+	namespace, _ := ctx.Value(namespaceKey{}).(string)
+	return namespace
+}
+
+// assignNamespace reads the request's namespace, stripping a /ns/{name} prefix so the rest of
+// the path is routed as usual. A prefix takes precedence over the header.
+func assignNamespace(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		namespace := r.Header.Get(namespaceHeader)
+		if rest, found := strings.CutPrefix(r.URL.Path, namespacePrefix); found {
+			var path string
+			namespace, path, _ = strings.Cut(rest, "/")
+
+			r = r.Clone(r.Context())
+			r.URL.Path = "/" + path
+			r.URL.RawPath = ""
+		}
+
+		if namespace != "" && !validNamespace(namespace) {
+			err := invalidParameter("namespace", "namespace must be up to %d lowercase letters, digits, '-' or '_'", maxNamespaceLength)
+			err.Details["namespace"] = namespace
+			writeError(w, err)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), namespaceKey{}, namespace)))
+	})
+}
+
+func validNamespace(namespace string) bool {
+	if len(namespace) > maxNamespaceLength {
+		return false
+	}
+	for _, c := range namespace {
+		if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '-' && c != '_' {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"cmp"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// requestLanguage returns the `language` query parameter. Without one it negotiates the
+// language from the Accept-Language header, falling back to English.
+func requestLanguage(r *http.Request) string {
+	if language := r.URL.Query().Get("language"); language != "" {
+		return language
+	}
+
+	return preferredLanguage(r.Header.Get("Accept-Language"))
+}
+
+// preferredLanguage returns the Wikipedia edition the Accept-Language header ranks highest,
+// matching on the primary subtag, so "de-CH" picks from de. It returns "en" if the header
+// names none of the editions.
+func preferredLanguage(header string) string {
+	type preference struct {
+		language string
+		weight   float64
+	}
+
+	var preferences []preference
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		weight := 1.0
+		if value, found := strings.CutPrefix(strings.TrimSpace(params), "q="); found {
+			var err error
+			if weight, err = strconv.ParseFloat(value, 64); err != nil {
+				continue
+			}
+		}
+
+		language, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+		if language != "" && language != "*" && weight > 0 {
+			preferences = append(preferences, preference{language, weight})
+		}
+	}
+	slices.SortStableFunc(preferences, func(a, b preference) int {
+		return cmp.Compare(b.weight, a.weight)
+	})
+
+	for _, preference := range preferences {
+		if _, found := randomArticleURL(preference.language); found {
+			return preference.language
+		}
+	}
+
+	return "en"
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// wikimediaOAuthURL is the base of the OAuth 2.0 endpoints of Wikimedia accounts.
+var wikimediaOAuthURL = "https://meta.wikimedia.org/w/rest.php/oauth2"
+
+// The OAuth consumer registered on Meta-Wiki. Login is disabled unless a client ID is set.
+var (
+	oauthClientID     string
+	oauthClientSecret string
+	oauthRedirectURL  string
+)
+
+const oauthStateCookie = "wwp_oauth_state"
+
+// requireOAuth hides the login endpoints unless both an OAuth consumer and a JWT secret,
+// which signs the tokens handed out after login, are configured.
+func requireOAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if oauthClientID == "" || jwtSecret == "" {
+			notFoundHandler(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// wikimediaLoginHandler sends the browser to Wikimedia to authorize the app, remembering a
+// random state in a cookie to check on the way back.
+func wikimediaLoginHandler(w http.ResponseWriter, r *http.Request) {
+	var buf [16]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		writeError(w, err)
+		return
+	}
+	state := hex.EncodeToString(buf[:])
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oauthStateCookie,
+		Value:    state,
+		Path:     "/",
+		MaxAge:   int((10 * time.Minute).Seconds()),
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	query := url.Values{
+		"response_type": {"code"},
+		"client_id":     {oauthClientID},
+		"state":         {state},
+	}
+	if oauthRedirectURL != "" {
+		query.Set("redirect_uri", oauthRedirectURL)
+	}
+	http.Redirect(w, r, wikimediaOAuthURL+"/authorize?"+query.Encode(), http.StatusFound)
+}
+
+// wikimediaCallbackHandler completes a login: it exchanges the authorization code for a
+// Wikimedia access token, looks up the account and returns our own token pair for it.
+// Picks made with the tokens are recorded in the account's history.
+func wikimediaCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	// An empty state would match a missing cookie, so neither may be empty.
+	state := query.Get("state")
+	cookie, err := r.Cookie(oauthStateCookie)
+	if err != nil || cookie.Value == "" || state == "" || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(state)) != 1 {
+		writeError(w, &apiError{
+			Status:  http.StatusUnauthorized,
+			Code:    CodeUnauthorized,
+			Message: "login state doesn't match; start again at /auth/wikimedia/login",
+		})
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: oauthStateCookie, Path: "/", MaxAge: -1})
+
+	if reason := query.Get("error"); reason != "" {
+		writeError(w, &apiError{
+			Status:  http.StatusUnauthorized,
+			Code:    CodeUnauthorized,
+			Message: fmt.Sprintf("login failed: %s", reason),
+		})
+		return
+	}
+	code := query.Get("code")
+	if code == "" {
+		writeError(w, invalidParameter("code", "code is required"))
+		return
+	}
+
+	accessToken, err := exchangeOAuthCode(r.Context(), code)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	username, err := fetchWikimediaUsername(r.Context(), accessToken)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	now := time.Now()
+	tokens, err := issueTokens("wikimedia:"+username, now, now)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tokens)
+}
+
+// exchangeOAuthCode trades an authorization code for a Wikimedia access token.
+func exchangeOAuthCode(ctx context.Context, code string) (string, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"client_id":     {oauthClientID},
+		"client_secret": {oauthClientSecret},
+	}
+	if oauthRedirectURL != "" {
+		form.Set("redirect_uri", oauthRedirectURL)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, wikimediaOAuthURL+"/access_token", strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := upstreamClient.Do(req)
+	if err != nil {
+		return "", upstreamError(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", &apiError{
+			Status:  http.StatusUnauthorized,
+			Code:    CodeUnauthorized,
+			Message: fmt.Sprintf("wikimedia rejected the authorization code: %s", resp.Status),
+		}
+	}
+
+	var body struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil || body.AccessToken == "" {
+		return "", upstreamError(fmt.Errorf("failed to decode wikimedia access token: %v", err))
+	}
+
+	return body.AccessToken, nil
+}
+
+// fetchWikimediaUsername returns the name of the account the access token belongs to.
+func fetchWikimediaUsername(ctx context.Context, accessToken string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, wikimediaOAuthURL+"/resource/profile", nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	resp, err := upstreamClient.Do(req)
+	if err != nil {
+		return "", upstreamError(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", upstreamError(fmt.Errorf("wikimedia profile returned %s", resp.Status))
+	}
+
+	var profile struct {
+		Username string `json:"username"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&profile); err != nil || profile.Username == "" {
+		return "", upstreamError(fmt.Errorf("failed to decode wikimedia profile: %v", err))
+	}
+
+	return profile.Username, nil
+}
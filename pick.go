@@ -0,0 +1,909 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/sync/errgroup"
+)
+
+// maxArticles is the largest `articles` a pick request may ask for.
+var maxArticles int
+
+// maxPoolArticles is how many articles a pick may fetch in all while its articles have fewer
+// unused words than `count`.
+var maxPoolArticles int
+
+// maxTimeout is the largest `timeout_ms` a pick request may ask for.
+var maxTimeout time.Duration
+
+// articleParallelism bounds how many articles a single pick fetches at once.
+const articleParallelism = 4
+
+// pickOptions are the query parameters accepted by /pick.
+type pickOptions struct {
+	Source          string
+	Project         string
+	URL             string
+	Language        string
+	Fallback        []string
+	Count           int
+	Articles        int
+	Safe            bool
+	Unique          bool
+	Peek            bool
+	Debug           bool
+	Meta            bool
+	NgramSize       int
+	Stats           bool
+	Context         bool
+	Excerpt         bool
+	Difficulty      bool
+	Scrabble        bool
+	Syllables       bool
+	Occurrences     bool
+	Audio           bool
+	Categories      bool
+	PreserveCase    bool
+	ForeignScript   bool
+	Script          string
+	StartsWith      string
+	EndsWith        string
+	Pattern         string
+	Crossword       string
+	Timeout         time.Duration
+	Lite            bool
+	GroupBy         string
+	Bias            string
+	Cursor          string
+	AcrossLanguages bool
+	PreferLength    lengthRange
+	LengthWeight    float64
+	Namespace       string
+}
+
+// fingerprint identifies the options for the response cache and idempotency keys: a hash of
+// every option encoded under its parameter name, so that it doesn't change meaning when
+// fields are added or reordered. New options must be added here.
+func (o pickOptions) fingerprint() string {
+	values := url.Values{"fallback": o.Fallback}
+	for name, value := range map[string]any{
+		"source":           o.Source,
+		"project":          o.Project,
+		"url":              o.URL,
+		"language":         o.Language,
+		"count":            o.Count,
+		"articles":         o.Articles,
+		"safe":             o.Safe,
+		"unique":           o.Unique,
+		"peek":             o.Peek,
+		"debug":            o.Debug,
+		"meta":             o.Meta,
+		"ngrams":           o.NgramSize,
+		"stats":            o.Stats,
+		"context":          o.Context,
+		"excerpt":          o.Excerpt,
+		"difficulty":       o.Difficulty,
+		"scrabble":         o.Scrabble,
+		"syllables":        o.Syllables,
+		"occurrences":      o.Occurrences,
+		"audio":            o.Audio,
+		"categories":       o.Categories,
+		"preserve_case":    o.PreserveCase,
+		"foreign_script":   o.ForeignScript,
+		"script":           o.Script,
+		"starts_with":      o.StartsWith,
+		"ends_with":        o.EndsWith,
+		"pattern":          o.Pattern,
+		"crossword":        o.Crossword,
+		"timeout_ms":       o.Timeout.Milliseconds(),
+		"lite":             o.Lite,
+		"group_by":         o.GroupBy,
+		"bias":             o.Bias,
+		"cursor":           o.Cursor,
+		"across_languages": o.AcrossLanguages,
+		"prefer_length":    fmt.Sprintf("%d-%d", o.PreferLength.Min, o.PreferLength.Max),
+		"length_weight":    strconv.FormatFloat(o.LengthWeight, 'g', -1, 64),
+		"namespace":        o.Namespace,
+	} {
+		values.Set(name, fmt.Sprint(value))
+	}
+
+	// Encode sorts the parameters by name.
+	sum := sha256.Sum256([]byte(values.Encode()))
+	return hex.EncodeToString(sum[:])
+}
+
+// parsePickOptions reads the /pick query parameters, applying defaults for missing values.
+func parsePickOptions(r *http.Request) (pickOptions, error) {
+	query := r.URL.Query()
+
+	opts := pickOptions{
+		Source:    query.Get("source"),
+		Language:  requestLanguage(r),
+		Count:     10,
+		Articles:  1,
+		NgramSize: 1,
+		Namespace: requestNamespace(r.Context()),
+	}
+	if opts.Source == "" {
+		opts.Source = defaultWordSource
+	}
+
+	// Namespaces listed in the config are unique across languages unless a pick says otherwise.
+	configMu.RLock()
+	opts.AcrossLanguages = crossLanguageNamespaces[opts.Namespace]
+	configMu.RUnlock()
+	if across := query.Get("across_languages"); across != "" {
+		opts.AcrossLanguages, _ = strconv.ParseBool(across)
+	}
+	if _, found := wordSources[opts.Source]; !found {
+		return opts, invalidParameter("source", "unknown source: %s", opts.Source)
+	}
+
+	if project := query.Get("project"); project != "" && project != "wikipedia" {
+		if !wikimediaProjects[project] {
+			return opts, invalidParameter("project", "unknown project: %s", project)
+		}
+		if opts.Source != "wikipedia" {
+			return opts, invalidParameter("project", "project only applies to source=wikipedia")
+		}
+		opts.Project = project
+	}
+
+	opts.Lite, _ = strconv.ParseBool(query.Get("lite"))
+	if opts.Lite && opts.Source != "wikipedia" {
+		return opts, invalidParameter("lite", "lite only applies to source=wikipedia")
+	}
+
+	if opts.URL = query.Get("url"); opts.URL != "" {
+		if _, err := checkURL(opts.URL); err != nil {
+			return opts, err
+		}
+		if opts.Project != "" || opts.Lite {
+			return opts, invalidParameter("url", "url can't be combined with project or lite")
+		}
+	}
+
+	if fallback := query.Get("fallback"); fallback != "" {
+		for _, language := range strings.Split(fallback, ",") {
+			if language = strings.TrimSpace(language); language != "" {
+				opts.Fallback = append(opts.Fallback, language)
+			}
+		}
+	}
+
+	if count := query.Get("count"); count != "" {
+		value, err := strconv.Atoi(count)
+		if err != nil || value < 1 {
+			return opts, invalidParameter("count", "count must be a positive integer")
+		}
+		if value > maxCount {
+			err := invalidParameter("count", "count must not exceed %d", maxCount)
+			err.Details["max_count"] = maxCount
+			return opts, err
+		}
+		opts.Count = value
+	}
+
+	if opts.Lite && opts.Count > maxLiteCount {
+		err := invalidParameter("count", "count must not exceed %d with lite=true", maxLiteCount)
+		err.Details["max_count"] = maxLiteCount
+		return opts, err
+	}
+
+	if articles := query.Get("articles"); articles != "" {
+		value, err := strconv.Atoi(articles)
+		if err != nil || value < 1 || value > maxArticles {
+			err := invalidParameter("articles", "articles must be between 1 and %d", maxArticles)
+			err.Details["max_articles"] = maxArticles
+			return opts, err
+		}
+		opts.Articles = value
+	}
+	if opts.URL != "" && opts.Articles > 1 {
+		return opts, invalidParameter("articles", "articles can't be combined with url, which names a single page")
+	}
+
+	if timeout := query.Get("timeout_ms"); timeout != "" {
+		value, err := strconv.Atoi(timeout)
+		if err != nil || value < 1 || time.Duration(value)*time.Millisecond > maxTimeout {
+			err := invalidParameter("timeout_ms", "timeout_ms must be between 1 and %d", maxTimeout.Milliseconds())
+			err.Details["max_timeout_ms"] = maxTimeout.Milliseconds()
+			return opts, err
+		}
+		opts.Timeout = time.Duration(value) * time.Millisecond
+	}
+
+	var err error
+	opts.Safe = safeByDefault
+	if safe := query.Get("safe"); safe != "" {
+		opts.Safe, err = strconv.ParseBool(safe)
+		if err != nil {
+			return opts, invalidParameter("safe", "safe must be true or false")
+		}
+	}
+
+	opts.Unique = true
+	if unique := query.Get("unique"); unique != "" {
+		opts.Unique, err = strconv.ParseBool(unique)
+		if err != nil {
+			return opts, invalidParameter("unique", "unique must be true or false")
+		}
+	}
+
+	opts.Peek, _ = strconv.ParseBool(query.Get("peek"))
+	// A debug pick is a dry run, so tuning filters doesn't use up words.
+	if opts.Debug, _ = strconv.ParseBool(query.Get("debug")); opts.Debug {
+		opts.Peek = true
+	}
+	opts.Meta, _ = strconv.ParseBool(query.Get("meta"))
+	opts.Stats, _ = strconv.ParseBool(query.Get("stats"))
+	opts.Context, _ = strconv.ParseBool(query.Get("context"))
+	opts.Excerpt, _ = strconv.ParseBool(query.Get("excerpt"))
+	opts.Difficulty, _ = strconv.ParseBool(query.Get("difficulty"))
+	opts.Scrabble, _ = strconv.ParseBool(query.Get("scrabble"))
+	opts.Syllables, _ = strconv.ParseBool(query.Get("syllables"))
+	opts.Occurrences, _ = strconv.ParseBool(query.Get("occurrences"))
+	opts.Audio, _ = strconv.ParseBool(query.Get("audio"))
+	opts.Categories, _ = strconv.ParseBool(query.Get("categories"))
+	opts.PreserveCase, _ = strconv.ParseBool(query.Get("preserve_case"))
+	opts.ForeignScript, _ = strconv.ParseBool(query.Get("foreign_script"))
+
+	opts.Script = strings.ToLower(query.Get("script"))
+	if _, ok := requestedScripts(opts.Script); opts.Script != "" && !ok {
+		err := invalidParameter("script", "script must name Unicode scripts, such as latin or cyrillic,latin")
+		err.Details["script"] = opts.Script
+		return opts, err
+	}
+
+	opts.StartsWith = ToLowerLanguage(normalizeApostrophes(query.Get("starts_with")), opts.Language)
+	opts.EndsWith = ToLowerLanguage(normalizeApostrophes(query.Get("ends_with")), opts.Language)
+	opts.Pattern = normalizeApostrophes(query.Get("pattern"))
+	if opts.Pattern != "" {
+		if _, err := compilePattern(opts.Pattern); err != nil {
+			return opts, invalidParameter("pattern", "invalid pattern: %v", err)
+		}
+	}
+
+	opts.GroupBy = query.Get("group_by")
+	if opts.GroupBy != "" && opts.GroupBy != "section" {
+		return opts, invalidParameter("group_by", "group_by must be section")
+	}
+
+	opts.Cursor = query.Get("cursor")
+
+	opts.Bias = query.Get("bias")
+	if opts.Bias != "" && opts.Bias != biasRare && opts.Bias != biasCommon {
+		return opts, invalidParameter("bias", "bias must be rare or common")
+	}
+
+	if preferLength := query.Get("prefer_length"); preferLength != "" {
+		opts.PreferLength, err = parseLengthRange(preferLength)
+		if err != nil {
+			return opts, invalidParameter("prefer_length", "prefer_length must be a length or a range like 6-10")
+		}
+
+		opts.LengthWeight = defaultLengthWeight
+		if weight := query.Get("length_weight"); weight != "" {
+			opts.LengthWeight, err = strconv.ParseFloat(weight, 64)
+			if err != nil || opts.LengthWeight < 1 || opts.LengthWeight > maxLengthWeight {
+				return opts, invalidParameter("length_weight", "length_weight must be between 1 and %d", maxLengthWeight)
+			}
+		}
+	}
+
+	if ngrams := query.Get("ngrams"); ngrams != "" {
+		opts.NgramSize, err = strconv.Atoi(ngrams)
+		if err != nil || opts.NgramSize < 1 || opts.NgramSize > 3 {
+			return opts, invalidParameter("ngrams", "ngrams must be 1, 2 or 3")
+		}
+	}
+
+	opts.Crossword = query.Get("crossword")
+	if opts.Crossword != "" {
+		if opts.NgramSize > 1 {
+			return opts, invalidParameter("crossword", "crossword can't be combined with ngrams")
+		}
+		if _, err := compileCrossword(opts.Crossword); err != nil {
+			return opts, invalidParameter("crossword", "invalid crossword: %v", err)
+		}
+	}
+
+	return opts, nil
+}
+
+// candidatePool is the set of words a pick can be drawn from for one language.
+type candidatePool struct {
+	Language   string
+	Articles   []*Article
+	Words      []string
+	UsedBefore map[string]struct{}
+	Confidence *float64
+
+	// Sources maps each word to the first article it was found in.
+	Sources map[string]*Article
+
+	// Cases maps each word to how it was written, when `preserve_case=true`.
+	Cases map[string]string
+
+	// locations maps each word to where it was first found, when `group_by=section`.
+	locations map[string]wordLocation
+
+	// extracted holds the words of the articles before the filters.
+	extracted []string
+
+	// cursorWords holds the keys of the words already picked with the pick's cursor.
+	cursorWords map[string]struct{}
+
+	// diagnostics records the extraction of the articles when `debug=true`.
+	diagnostics *Diagnostics
+
+	// timing accumulates how long each stage took and fetches counts the articles fetched,
+	// for `meta=true`.
+	timing  stageTimes
+	fetches int
+
+	opts       pickOptions
+	wordLists  *wordLists
+	filter     *wordFilter
+	confidence []float64
+}
+
+// wordLocation is a paragraph of one of the pool's articles.
+type wordLocation struct {
+	article   int
+	paragraph int
+}
+
+// Available counts the distinct candidate words that haven't been used before.
+func (p *candidatePool) Available() int {
+	seen := make(map[string]struct{})
+	for _, word := range p.Words {
+		key := wordKey(word)
+		_, used := p.UsedBefore[key]
+		_, picked := p.cursorWords[key]
+		if !used && !picked {
+			seen[key] = struct{}{}
+		}
+	}
+
+	return len(seen)
+}
+
+// distinct counts the distinct candidate words, used or not.
+func (p *candidatePool) distinct() int {
+	seen := make(map[string]struct{})
+	for _, word := range p.Words {
+		seen[wordKey(word)] = struct{}{}
+	}
+
+	return len(seen)
+}
+
+// usedLanguage is the language whose used words the pool's words must not be, or "" for every
+// language when the pick is unique across languages.
+func (p *candidatePool) usedLanguage() string {
+	if p.opts.AcrossLanguages {
+		return ""
+	}
+
+	return p.Language
+}
+
+// Paragraphs returns the paragraphs of every article in the pool, in the order they were added.
+func (p *candidatePool) Paragraphs() []string {
+	var paragraphs []string
+	for _, article := range p.Articles {
+		paragraphs = append(paragraphs, article.Paragraphs...)
+	}
+
+	return paragraphs
+}
+
+// addArticle adds the article's words to the pool, after the safe, word list and pattern filters.
+func (p *candidatePool) addArticle(article *Article, fetched time.Duration) {
+	parseStart := time.Now()
+	p.Articles = append(p.Articles, article)
+	if p.opts.PreserveCase {
+		originalCases(p.Cases, article.Paragraphs, p.opts.NgramSize, p.Language)
+	}
+
+	if p.opts.GroupBy == "section" {
+		for i, paragraph := range article.Paragraphs {
+			for _, word := range p.split([]string{paragraph}) {
+				if _, found := p.locations[word]; !found {
+					p.locations[word] = wordLocation{article: len(p.Articles) - 1, paragraph: i}
+				}
+			}
+		}
+	}
+
+	diagnostics := p.diagnostics.addArticle(article, fetched)
+	start := time.Now()
+	words := p.split(article.Paragraphs)
+	diagnostics.tokenized(start, len(words))
+	p.extracted = append(p.extracted, words...)
+
+	filterStart := time.Now()
+	p.timing.parse += filterStart.Sub(parseStart)
+	if p.opts.Safe {
+		start = time.Now()
+		words = RemoveProfanity(words, p.Language)
+		diagnostics.filtered("safe", start, len(words))
+	}
+	start = time.Now()
+	words = p.wordLists.Apply(words)
+	diagnostics.filtered("word_lists", start, len(words))
+	start = time.Now()
+	words = p.filter.Apply(words)
+	diagnostics.filtered("filters", start, len(words))
+	p.timing.filter += time.Since(filterStart)
+
+	for _, word := range words {
+		if _, found := p.Sources[word]; !found {
+			p.Sources[word] = article
+		}
+	}
+	p.Words = append(p.Words, words...)
+}
+
+// split returns the words of the paragraphs, or their n-grams when the pick asks for them.
+func (p *candidatePool) split(paragraphs []string) []string {
+	if p.opts.NgramSize > 1 {
+		return NgramsFromParagraphs(paragraphs, p.opts.NgramSize, p.Language)
+	}
+
+	return WordsFromParagraphs(paragraphs, p.Language)
+}
+
+// groupBySection groups the words by the article section they were first found in. Sections
+// are ordered as they appear in the articles, and words as given.
+func (p *candidatePool) groupBySection(words []string) []SectionWords {
+	type sectionKey struct {
+		article int
+		section string
+	}
+
+	groups := make(map[sectionKey]*SectionWords)
+	first := make(map[sectionKey]wordLocation)
+	var keys []sectionKey
+	for _, word := range words {
+		location := p.locations[word]
+		article := p.Articles[location.article]
+		key := sectionKey{location.article, article.section(location.paragraph)}
+
+		group, found := groups[key]
+		if !found {
+			group = &SectionWords{Article: article.Title, Section: key.section}
+			groups[key] = group
+			first[key] = location
+			keys = append(keys, key)
+		} else if location.paragraph < first[key].paragraph {
+			first[key] = location
+		}
+		group.Words = append(group.Words, word)
+	}
+
+	slices.SortFunc(keys, func(a, b sectionKey) int {
+		return cmp.Or(cmp.Compare(a.article, b.article), cmp.Compare(first[a].paragraph, first[b].paragraph))
+	})
+	sections := make([]SectionWords, 0, len(keys))
+	for _, key := range keys {
+		sections = append(sections, *groups[key])
+	}
+
+	return sections
+}
+
+// fetchArticles fetches n articles concurrently and adds them to the pool. If ctx's deadline
+// passes first, the articles that did arrive are added and only the failure to fetch any
+// is an error.
+func (p *candidatePool) fetchArticles(ctx context.Context, source WordSource, n int) error {
+	articles := make([]*Article, n)
+	scores := make([]*float64, n)
+	durations := make([]time.Duration, n)
+	attempts := make([]int, n)
+
+	fetchStart := time.Now()
+	group, groupCtx := errgroup.WithContext(ctx)
+	group.SetLimit(articleParallelism)
+	for i := range n {
+		group.Go(func() error {
+			start := time.Now()
+			article, score, tries, err := fetchCheckedArticle(groupCtx, source, p.Language)
+			articles[i], scores[i], durations[i], attempts[i] = article, score, time.Since(start), tries
+			return err
+		})
+	}
+	err := group.Wait()
+	p.timing.fetch += time.Since(fetchStart)
+	for _, tries := range attempts {
+		p.fetches += tries
+	}
+	if err != nil {
+		if !errors.Is(ctx.Err(), context.DeadlineExceeded) || !slices.ContainsFunc(articles, func(a *Article) bool { return a != nil }) {
+			return err
+		}
+	}
+
+	storeStart := time.Now()
+	if err := recordWordCounts(ctx, p.Language, articles); err != nil {
+		// The counts are only analytics; the pick goes on without them.
+		slog.WarnContext(ctx, "Failed to record word counts", "language", p.Language, "error", err)
+	}
+	p.timing.store += time.Since(storeStart)
+
+	for i, article := range articles {
+		if article == nil {
+			continue
+		}
+		p.addArticle(article, durations[i])
+		if scores[i] != nil {
+			p.confidence = append(p.confidence, *scores[i])
+		}
+	}
+
+	if len(p.confidence) > 0 {
+		var total float64
+		for _, score := range p.confidence {
+			total += score
+		}
+		average := total / float64(len(p.confidence))
+		p.Confidence = &average
+	}
+
+	return nil
+}
+
+// fetchCheckedArticle fetches an article, refetching up to maxLanguageAttempts times while
+// its text doesn't look like the language. The score is nil for languages without a profile.
+// It also returns how many articles it fetched.
+func fetchCheckedArticle(ctx context.Context, source WordSource, language string) (*Article, *float64, int, error) {
+	for attempt := 1; ; attempt++ {
+		fetchCtx, span := tracer.Start(ctx, "fetch_article", trace.WithAttributes(
+			attribute.String("language", language),
+			attribute.Int("attempt", attempt),
+		))
+		article, err := source.Fetch(fetchCtx, language)
+		endSpan(span, err)
+		if err != nil {
+			return nil, nil, attempt - 1, err
+		}
+
+		detected, score, known := DetectLanguage(article.Paragraphs, language)
+		if !known {
+			return article, nil, attempt, nil
+		}
+		if detected == language || attempt == maxLanguageAttempts {
+			return article, &score, attempt, nil
+		}
+	}
+}
+
+// newCandidatePool returns an empty pool for picking in the language with the options.
+func newCandidatePool(language string, opts pickOptions) (*candidatePool, error) {
+	lists, err := loadWordLists(opts.Namespace, language)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := &candidatePool{
+		Language:   language,
+		UsedBefore: map[string]struct{}{},
+		Sources:    make(map[string]*Article),
+		Cases:      make(map[string]string),
+		locations:  make(map[string]wordLocation),
+		opts:       opts,
+		wordLists:  lists,
+		filter:     newWordFilter(opts, language),
+	}
+	if opts.Debug {
+		pool.diagnostics = &Diagnostics{}
+	}
+
+	return pool, nil
+}
+
+// loadCandidatePool fetches random articles in the language and prepares their words for picking.
+// If the articles have fewer unused words than requested, more batches are fetched, up to
+// maxPoolArticles articles in all.
+func loadCandidatePool(ctx context.Context, source WordSource, language string, opts pickOptions) (*candidatePool, error) {
+	pool, err := newCandidatePool(language, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// This read is only used to judge whether the pool is big enough; the pick itself
+	// re-reads the used words inside its transaction.
+	storeStart := time.Now()
+	if opts.Unique || opts.Peek {
+		_, span := tracer.Start(ctx, "store.read_used")
+		pool.UsedBefore, err = getUsedWords(db, opts.Namespace, pool.usedLanguage())
+		endSpan(span, err)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.Cursor != "" {
+		pool.cursorWords, err = getCursorWords(db, opts.Namespace, opts.Cursor)
+		if err != nil {
+			return nil, err
+		}
+	}
+	pool.timing.store += time.Since(storeStart)
+
+	// timeout_ms bounds the fetches only, so a pick that runs out of time still stores and
+	// returns the words it has.
+	fetchCtx := ctx
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		fetchCtx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	if err := pool.fetchArticles(fetchCtx, source, opts.Articles); err != nil {
+		return nil, timeoutError(ctx, fetchCtx, opts, err)
+	}
+
+	// Top up with more batches while words are missing, stopping early if a batch adds none,
+	// as with a source that keeps returning the same text. A url pick has only its page.
+	for available := pool.Available(); opts.URL == "" && available < opts.Count && len(pool.Articles) < maxPoolArticles; {
+		err := pool.fetchArticles(fetchCtx, source, min(opts.Articles, maxPoolArticles-len(pool.Articles)))
+		if fetchCtx.Err() != nil {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		previous := available
+		if available = pool.Available(); available == previous {
+			break
+		}
+	}
+
+	return pool, nil
+}
+
+// timeoutError reports err as an upstream timeout if it happened because fetchCtx ran out of
+// the request's timeout_ms while ctx is still live.
+func timeoutError(ctx, fetchCtx context.Context, opts pickOptions, err error) error {
+	if ctx.Err() != nil || !errors.Is(fetchCtx.Err(), context.DeadlineExceeded) {
+		return err
+	}
+
+	return &apiError{
+		Status:  http.StatusGatewayTimeout,
+		Code:    CodeUpstreamTimeout,
+		Message: fmt.Sprintf("no article arrived within %d ms", opts.Timeout.Milliseconds()),
+		Details: map[string]any{"timeout_ms": opts.Timeout.Milliseconds()},
+	}
+}
+
+func pickHandler(w http.ResponseWriter, r *http.Request) {
+	opts, err := parsePickOptions(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	result, err := idempotentPick(r.Context(), r.Header.Get(idempotencyKeyHeader), opts)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	entry := AuditEntry{
+		At:       time.Now(),
+		Client:   clientIP(r),
+		User:     requestUser(r.Context()),
+		Language: result.language,
+		Count:    len(result.words),
+		Articles: result.articles,
+		Words:    result.words,
+		Cached:   result.cached,
+	}
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		entry.Key = apiKeySubject(key)
+	}
+	if err := retryBusy(r.Context(), func() error { return recordAudit(db, entry) }); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to record pick in the audit log", "error", err)
+	}
+
+	if result.replayed {
+		w.Header().Set("Idempotent-Replayed", "true")
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(result.body)
+}
+
+// pick runs a pick with the options and builds its response.
+func pick(ctx context.Context, opts pickOptions) (*Response, error) {
+	start := time.Now()
+	if opts.Cursor == newCursor {
+		err := retryTx(ctx, func(tx *sql.Tx) (err error) {
+			opts.Cursor, err = createCursor(tx, opts.Namespace)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Try the requested language first, then each fallback in order, settling for the
+	// last supported language if none of them has enough unused words.
+	var source WordSource = prefetchedSource{wordSources[opts.Source], opts.Source}
+	switch {
+	case opts.URL != "":
+		source = &urlSource{address: opts.URL}
+	case opts.Project != "" || opts.Lite:
+		source = wikimediaSource{project: cmp.Or(opts.Project, "wikipedia"), lite: opts.Lite}
+	}
+	var pool *candidatePool
+	for _, language := range append([]string{opts.Language}, opts.Fallback...) {
+		if !source.Supports(language) {
+			continue
+		}
+
+		var err error
+		pool, err = loadCandidatePool(ctx, source, language, opts)
+		if err != nil && corpusFallback && opts.Source == "wikipedia" && opts.URL == "" && isUpstreamFailure(err) && (corpusSource{}).Supports(language) {
+			slog.WarnContext(ctx, "Wikipedia unreachable, picking from the embedded corpus", "language", language, "error", err)
+			pool, err = loadCandidatePool(ctx, corpusSource{}, language, opts)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if pool.Available() >= opts.Count {
+			break
+		}
+	}
+	if pool == nil {
+		return nil, &apiError{
+			Status:  http.StatusBadRequest,
+			Code:    CodeUnsupportedLanguage,
+			Message: fmt.Sprintf("unsupported language: %s", opts.Language),
+			Details: map[string]any{"language": opts.Language, "fallback": opts.Fallback},
+		}
+	}
+
+	var firstNWords []string
+	if opts.Unique && !opts.Peek {
+		var err error
+		storeStart := time.Now()
+		firstNWords, err = pickAndStore(ctx, pool, opts.Count)
+		if err != nil {
+			return nil, err
+		}
+		pool.timing.store += time.Since(storeStart) - pool.timing.sample
+	} else {
+		// A peek leaves out the used words like a unique pick, but doesn't use up its words.
+		_, span := tracer.Start(ctx, "sample")
+		sampleStart := time.Now()
+		firstNWords = pool.sample(opts.Count, pool.UsedBefore)
+		pool.timing.sample = time.Since(sampleStart)
+		if pool.diagnostics != nil {
+			pool.diagnostics.SampleUS = pool.timing.sample.Microseconds()
+		}
+		span.End()
+		if opts.Cursor != "" && !opts.Peek {
+			storeStart := time.Now()
+			err := retryTx(ctx, func(tx *sql.Tx) error {
+				return recordCursorWords(tx, opts.Cursor, firstNWords)
+			})
+			if err != nil {
+				return nil, err
+			}
+			pool.timing.store += time.Since(storeStart)
+		}
+	}
+	if len(firstNWords) == 0 {
+		return nil, &apiError{
+			Status:  http.StatusConflict,
+			Code:    CodePoolExhausted,
+			Message: "no words left to pick in the source articles",
+			Details: map[string]any{"language": pool.Language},
+		}
+	}
+
+	response := &Response{
+		APIVersion:         apiVersion,
+		Language:           pool.Language,
+		Words:              firstNWords,
+		Requested:          opts.Count,
+		Delivered:          len(firstNWords),
+		Exhausted:          len(firstNWords) < opts.Count,
+		Warnings:           pool.warnings(firstNWords),
+		Cursor:             opts.Cursor,
+		Peek:               opts.Peek,
+		ArticleCount:       len(pool.Articles),
+		LanguageConfidence: pool.Confidence,
+	}
+	for _, article := range pool.Articles {
+		response.Sources = append(response.Sources, Source{Title: article.Title, URL: article.URL, Image: article.Image})
+	}
+	if pool.Language != opts.Language {
+		response.RequestedLanguage = opts.Language
+	}
+	if opts.Stats {
+		response.Stats = computeStats(pool.extracted, pool.UsedBefore)
+	}
+	if opts.Debug {
+		response.Debug = pool.diagnostics
+		response.Debug.Candidates = pool.distinct()
+		response.Debug.Used = response.Debug.Candidates - pool.Available()
+	}
+	if opts.Context {
+		response.Contexts = FindContexts(pool.Paragraphs(), firstNWords, pool.Language)
+	}
+	if opts.Excerpt {
+		response.Excerpt = FirstParagraph(pool.Articles[0].Paragraphs)
+	}
+	if opts.Difficulty {
+		response.Difficulty = make(map[string]int, len(firstNWords))
+		for _, word := range firstNWords {
+			response.Difficulty[word] = Difficulty(word, pool.Language)
+		}
+	}
+	if opts.Scrabble {
+		response.Scrabble = make(map[string]int, len(firstNWords))
+		for _, word := range firstNWords {
+			response.Scrabble[word] = ScrabbleScore(word, pool.Language)
+		}
+	}
+	if opts.Syllables {
+		response.Syllables = make(map[string]int, len(firstNWords))
+		for _, word := range firstNWords {
+			response.Syllables[word] = Syllables(word, pool.Language)
+		}
+	}
+	if opts.Occurrences {
+		response.Occurrences = make(map[string]int, len(firstNWords))
+		for _, word := range firstNWords {
+			response.Occurrences[word] = 0
+		}
+		for _, word := range pool.Words {
+			if count, found := response.Occurrences[word]; found {
+				response.Occurrences[word] = count + 1
+			}
+		}
+	}
+	if opts.Audio {
+		response.Audio = lookupAudio(ctx, firstNWords, pool.Language)
+	}
+	if opts.Categories {
+		response.Categories = make(map[string][]string, len(firstNWords))
+		for _, word := range firstNWords {
+			if article := pool.Sources[word]; article != nil && len(article.Categories) > 0 {
+				response.Categories[word] = article.Categories
+			}
+		}
+	}
+	if opts.GroupBy == "section" {
+		response.Sections = pool.groupBySection(firstNWords)
+	}
+	if opts.PreserveCase {
+		response.restoreCase(pool.Cases)
+	}
+	if opts.Meta {
+		response.Meta = pool.meta(start)
+	}
+
+	return response, nil
+}
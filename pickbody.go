@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// maxPickBodySize bounds the JSON body of POST /pick.
+const maxPickBodySize = 64 << 10
+
+// PickRequest is the body accepted by POST /pick. Its fields are the /pick query parameters
+// under the same names, with lists as arrays. Omitted fields take the same defaults as missing
+// query parameters.
+type PickRequest struct {
+	Source          string   `json:"source"`
+	Project         string   `json:"project"`
+	URL             string   `json:"url"`
+	Language        string   `json:"language"`
+	Fallback        []string `json:"fallback"`
+	Count           *int     `json:"count"`
+	Articles        *int     `json:"articles"`
+	TimeoutMS       *int     `json:"timeout_ms"`
+	Ngrams          *int     `json:"ngrams"`
+	Safe            *bool    `json:"safe"`
+	Unique          *bool    `json:"unique"`
+	AcrossLanguages *bool    `json:"across_languages"`
+	Lite            bool     `json:"lite"`
+	Peek            bool     `json:"peek"`
+	Debug           bool     `json:"debug"`
+	Meta            bool     `json:"meta"`
+	Stats           bool     `json:"stats"`
+	Context         bool     `json:"context"`
+	Excerpt         bool     `json:"excerpt"`
+	Difficulty      bool     `json:"difficulty"`
+	Scrabble        bool     `json:"scrabble"`
+	Syllables       bool     `json:"syllables"`
+	Occurrences     bool     `json:"occurrences"`
+	Audio           bool     `json:"audio"`
+	Categories      bool     `json:"categories"`
+	PreserveCase    bool     `json:"preserve_case"`
+	ForeignScript   bool     `json:"foreign_script"`
+	Script          []string `json:"script"`
+	StartsWith      string   `json:"starts_with"`
+	EndsWith        string   `json:"ends_with"`
+	Pattern         string   `json:"pattern"`
+	Crossword       string   `json:"crossword"`
+	GroupBy         string   `json:"group_by"`
+	Bias            string   `json:"bias"`
+	Cursor          string   `json:"cursor"`
+	PreferLength    string   `json:"prefer_length"`
+	LengthWeight    *float64 `json:"length_weight"`
+}
+
+// query returns the request as /pick query parameters, overriding those in query.
+func (p PickRequest) query(query url.Values) url.Values {
+	setString := func(name, value string) {
+		if value != "" {
+			query.Set(name, value)
+		}
+	}
+	setInt := func(name string, value *int) {
+		if value != nil {
+			query.Set(name, strconv.Itoa(*value))
+		}
+	}
+	setBool := func(name string, value bool) {
+		if value {
+			query.Set(name, "true")
+		}
+	}
+	setOptionalBool := func(name string, value *bool) {
+		if value != nil {
+			query.Set(name, strconv.FormatBool(*value))
+		}
+	}
+
+	setString("source", p.Source)
+	setString("project", p.Project)
+	setString("url", p.URL)
+	setString("language", p.Language)
+	setString("fallback", strings.Join(p.Fallback, ","))
+	setInt("count", p.Count)
+	setInt("articles", p.Articles)
+	setInt("timeout_ms", p.TimeoutMS)
+	setInt("ngrams", p.Ngrams)
+	setOptionalBool("safe", p.Safe)
+	setOptionalBool("unique", p.Unique)
+	setOptionalBool("across_languages", p.AcrossLanguages)
+	setBool("lite", p.Lite)
+	setBool("peek", p.Peek)
+	setBool("debug", p.Debug)
+	setBool("meta", p.Meta)
+	setBool("stats", p.Stats)
+	setBool("context", p.Context)
+	setBool("excerpt", p.Excerpt)
+	setBool("difficulty", p.Difficulty)
+	setBool("scrabble", p.Scrabble)
+	setBool("syllables", p.Syllables)
+	setBool("occurrences", p.Occurrences)
+	setBool("audio", p.Audio)
+	setBool("categories", p.Categories)
+	setBool("preserve_case", p.PreserveCase)
+	setBool("foreign_script", p.ForeignScript)
+	setString("script", strings.Join(p.Script, ","))
+	setString("starts_with", p.StartsWith)
+	setString("ends_with", p.EndsWith)
+	setString("pattern", p.Pattern)
+	setString("crossword", p.Crossword)
+	setString("group_by", p.GroupBy)
+	setString("bias", p.Bias)
+	setString("cursor", p.Cursor)
+	setString("prefer_length", p.PreferLength)
+	if p.LengthWeight != nil {
+		query.Set("length_weight", strconv.FormatFloat(*p.LengthWeight, 'g', -1, 64))
+	}
+
+	return query
+}
+
+// pickBodyHandler serves POST /pick, which takes its options as a JSON PickRequest instead of
+// query parameters. Unknown fields and values of the wrong type are rejected; the options are
+// then validated like those of GET /pick.
+func pickBodyHandler(w http.ResponseWriter, r *http.Request) {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxPickBodySize))
+	decoder.DisallowUnknownFields()
+
+	var body PickRequest
+	if err := decoder.Decode(&body); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) && typeErr.Field != "" {
+			writeError(w, invalidParameter(typeErr.Field, "%s can't be a JSON %s", typeErr.Field, typeErr.Value))
+			return
+		}
+		writeError(w, invalidParameter("body", "invalid JSON body: %v", err))
+		return
+	}
+
+	r = r.Clone(r.Context())
+	r.URL.RawQuery = body.query(r.URL.Query()).Encode()
+	pickHandler(w, r)
+}
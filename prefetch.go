@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// prefetchDepth is how many articles are kept ready per prefetched language.
+var prefetchDepth int
+
+// prefetchRetryDelay is how long a prefetcher waits after a failed fetch.
+const prefetchRetryDelay = 5 * time.Second
+
+// defaultLongpollWait is how long /pick/longpoll holds a request that doesn't set `wait_ms`,
+// unless -max-timeout is shorter.
+const defaultLongpollWait = 25 * time.Second
+
+// prefetcher keeps up to prefetchDepth articles of one language fetched ahead of the picks
+// that will use them.
+type prefetcher struct {
+	source   WordSource
+	language string
+
+	mu       sync.Mutex
+	articles []*Article
+	// ready is closed, and replaced, whenever an article is added.
+	ready chan struct{}
+	// taken wakes the fetch loop when an article has been used.
+	taken chan struct{}
+}
+
+// prefetchers holds the prefetcher of each source and language set up by startPrefetching,
+// keyed by "source:language". It isn't written after startup.
+var prefetchers = make(map[string]*prefetcher)
+
+// prefetcherFor returns the prefetcher of the source and language, or nil if it isn't
+// prefetched.
+func prefetcherFor(source string, language string) *prefetcher {
+	return prefetchers[source+":"+language]
+}
+
+// startPrefetching starts prefetching articles from the default source for a comma-separated
+// list of languages.
+func startPrefetching(languages string) error {
+	source := wordSources[defaultWordSource]
+	for _, language := range strings.Split(languages, ",") {
+		if language = strings.TrimSpace(language); language == "" {
+			continue
+		}
+		if !source.Supports(language) {
+			return fmt.Errorf("source %s doesn't support language %s", defaultWordSource, language)
+		}
+
+		key := defaultWordSource + ":" + language
+		if _, found := prefetchers[key]; found {
+			continue
+		}
+		p := &prefetcher{
+			source:   source,
+			language: language,
+			ready:    make(chan struct{}),
+			taken:    make(chan struct{}, 1),
+		}
+		prefetchers[key] = p
+		go p.run()
+	}
+
+	return nil
+}
+
+// run fetches articles for as long as the server runs, waiting while prefetchDepth are ready.
+func (p *prefetcher) run() {
+	for {
+		p.mu.Lock()
+		full := len(p.articles) >= prefetchDepth
+		p.mu.Unlock()
+		if full {
+			<-p.taken
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), maxTimeout)
+		article, err := p.source.Fetch(ctx, p.language)
+		cancel()
+		if err != nil {
+			slog.Warn("Failed to prefetch an article", "language", p.language, "error", err)
+			time.Sleep(max(prefetchRetryDelay, retryAfter(err)))
+			continue
+		}
+
+		p.mu.Lock()
+		p.articles = append(p.articles, article)
+		close(p.ready)
+		p.ready = make(chan struct{})
+		p.mu.Unlock()
+	}
+}
+
+// retryAfter returns how long an upstream that is throttled or failing asked us to wait.
+func retryAfter(err error) time.Duration {
+	var apiErr *apiError
+	if !errors.As(err, &apiErr) {
+		return 0
+	}
+	seconds, _ := apiErr.Details["retry_after_seconds"].(int)
+
+	return time.Duration(seconds) * time.Second
+}
+
+// take returns a prefetched article, or nil if none is ready.
+func (p *prefetcher) take() *Article {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.articles) == 0 {
+		return nil
+	}
+	article := p.articles[0]
+	p.articles = p.articles[1:]
+
+	select {
+	case p.taken <- struct{}{}:
+	default:
+	}
+
+	return article
+}
+
+// wait blocks until an article is ready or ctx is done.
+func (p *prefetcher) wait(ctx context.Context) error {
+	for {
+		p.mu.Lock()
+		if len(p.articles) > 0 {
+			p.mu.Unlock()
+			return nil
+		}
+		ready := p.ready
+		p.mu.Unlock()
+
+		select {
+		case <-ready:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// prefetchedSource serves a pick's articles from its prefetcher while any are ready and
+// fetches the rest from the source itself.
+type prefetchedSource struct {
+	WordSource
+	name string
+}
+
+func (s prefetchedSource) Fetch(ctx context.Context, language string) (*Article, error) {
+	if p := prefetcherFor(s.name, language); p != nil {
+		if article := p.take(); article != nil {
+			return article, nil
+		}
+	}
+
+	return s.WordSource.Fetch(ctx, language)
+}
+
+// longpollHandler holds a pick until an article of the language has been prefetched, up to
+// `wait_ms`, then picks from it like /pick. Clients polling it in a loop get each batch as
+// soon as it can be served without waiting on Wikipedia.
+func longpollHandler(w http.ResponseWriter, r *http.Request) {
+	opts, err := parsePickOptions(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	wait := min(defaultLongpollWait, maxTimeout)
+	if value := r.URL.Query().Get("wait_ms"); value != "" {
+		ms, err := strconv.Atoi(value)
+		if err != nil || ms < 1 || time.Duration(ms)*time.Millisecond > maxTimeout {
+			writeError(w, invalidParameter("wait_ms", "wait_ms must be between 1 and %d", maxTimeout.Milliseconds()))
+			return
+		}
+		wait = time.Duration(ms) * time.Millisecond
+	}
+
+	p := prefetcherFor(opts.Source, opts.Language)
+	if p == nil || opts.Project != "" || opts.Lite || opts.URL != "" {
+		writeError(w, &apiError{
+			Status:  http.StatusNotFound,
+			Code:    CodeNotFound,
+			Message: fmt.Sprintf("no articles are prefetched for source %s and language %s", opts.Source, opts.Language),
+			Details: map[string]any{"source": opts.Source, "language": opts.Language},
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), wait)
+	defer cancel()
+	if err := p.wait(ctx); err != nil {
+		if r.Context().Err() != nil {
+			return
+		}
+		writeError(w, &apiError{
+			Status:  http.StatusGatewayTimeout,
+			Code:    CodeUpstreamTimeout,
+			Message: fmt.Sprintf("no article was prefetched within %d ms", wait.Milliseconds()),
+			Details: map[string]any{"wait_ms": wait.Milliseconds()},
+		})
+		return
+	}
+
+	pickHandler(w, r)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bufio"
+	"embed"
+	"strings"
+)
+
+//go:embed profanity/*.txt
+var profanityFiles embed.FS
+
+// profanityByLanguage holds the offensive word lists, keyed by language: the embedded lists
+// and the words the config file adds to them. It is guarded by configMu.
+var profanityByLanguage = loadProfanityLists()
+
+// loadProfanityLists reads one word per line from profanity/<language>.txt.
+func loadProfanityLists() map[string]map[string]struct{} {
+	lists := make(map[string]map[string]struct{})
+
+	entries, err := profanityFiles.ReadDir("profanity")
+	if err != nil {
+		return lists
+	}
+
+	for _, entry := range entries {
+		language := strings.TrimSuffix(entry.Name(), ".txt")
+		file, err := profanityFiles.Open("profanity/" + entry.Name())
+		if err != nil {
+			continue
+		}
+
+		words := make(map[string]struct{})
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			word := ToLowerLanguage(strings.TrimSpace(scanner.Text()), language)
+			if word != "" {
+				words[word] = struct{}{}
+			}
+		}
+		file.Close()
+
+		lists[language] = words
+	}
+
+	return lists
+}
+
+// RemoveProfanity returns the words that are not on the offensive word list for the language.
+// Phrases are removed when any of their words is on the list.
+func RemoveProfanity(words []string, language string) []string {
+	configMu.RLock()
+	blocked := profanityByLanguage[language]
+	configMu.RUnlock()
+	if len(blocked) == 0 {
+		return words
+	}
+
+	clean := make([]string, 0, len(words))
+	for _, word := range words {
+		if !containsBlocked(word, blocked) {
+			clean = append(clean, word)
+		}
+	}
+
+	return clean
+}
+
+func containsBlocked(phrase string, blocked map[string]struct{}) bool {
+	for _, word := range strings.Fields(phrase) {
+		if _, found := blocked[word]; found {
+			return true
+		}
+	}
+
+	return false
+}
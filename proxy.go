@@ -0,0 +1,40 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// setOutboundProxy sends every upstream request through the proxy at the address, such as
+// "http://proxy.school.example:3128", instead of the one HTTP_PROXY and HTTPS_PROXY name.
+// Hosts listed in NO_PROXY are still reached directly.
+func setOutboundProxy(address string) error {
+	proxy, err := url.Parse(address)
+	if err != nil || proxy.Host == "" {
+		return fmt.Errorf("not an absolute URL: %q", address)
+	}
+	switch proxy.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return fmt.Errorf("unsupported proxy scheme %q; use http, https or socks5", proxy.Scheme)
+	}
+
+	proxyFunc := (&httpproxy.Config{
+		HTTPProxy:  address,
+		HTTPSProxy: address,
+		NoProxy:    cmp.Or(os.Getenv("NO_PROXY"), os.Getenv("no_proxy")),
+	}).ProxyFunc()
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = func(req *http.Request) (*url.URL, error) {
+		return proxyFunc(req.URL)
+	}
+	upstreamClient.Transport.(*throttlingTransport).next = transport
+
+	return nil
+}
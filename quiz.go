@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// maxQuizQuestions is the largest `questions` a quiz request may ask for.
+const maxQuizQuestions = 20
+
+// quizChoices is how many definitions each question offers, including the correct one.
+const quizChoices = 4
+
+// QuizQuestion asks for the definition of a word. Answer is the index of the correct choice.
+type QuizQuestion struct {
+	Word    string   `json:"word"`
+	Choices []string `json:"choices"`
+	Answer  int      `json:"answer"`
+}
+
+// QuizResponse is returned by /quiz.
+type QuizResponse struct {
+	Language  string         `json:"language"`
+	Questions []QuizQuestion `json:"questions"`
+}
+
+// quizHandler builds multiple-choice questions from picked words, with definitions from
+// Wiktionary. Quizzes don't mark their words as used.
+func quizHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	language := requestLanguage(r)
+
+	questions := 5
+	if value := query.Get("questions"); value != "" {
+		var err error
+		questions, err = strconv.Atoi(value)
+		if err != nil || questions < 1 || questions > maxQuizQuestions {
+			writeError(w, invalidParameter("questions", "questions must be between 1 and %d", maxQuizQuestions))
+			return
+		}
+	}
+
+	quiz, err := buildQuiz(r.Context(), requestNamespace(r.Context()), language, questions)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(quiz)
+}
+
+func buildQuiz(ctx context.Context, namespace string, language string, questions int) (*QuizResponse, error) {
+	// Many words have no Wiktionary entry, so pick more than needed.
+	response, err := pick(ctx, pickOptions{
+		Source:    defaultWordSource,
+		Namespace: namespace,
+		Language:  language,
+		Count:     min(questions*3, maxCount),
+		Articles:  1,
+		NgramSize: 1,
+		Safe:      safeByDefault,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	definitions := make(map[string]string)
+	var mu sync.Mutex
+	group, groupCtx := errgroup.WithContext(ctx)
+	group.SetLimit(articleParallelism)
+	for _, word := range response.Words {
+		group.Go(func() error {
+			definition, err := lookupDefinition(groupCtx, word, response.Language)
+			if err != nil || definition == "" {
+				// A word without a definition is just not used in the quiz.
+				return nil
+			}
+			mu.Lock()
+			definitions[word] = definition
+			mu.Unlock()
+			return nil
+		})
+	}
+	group.Wait()
+
+	var defined []string
+	for _, word := range response.Words {
+		if _, found := definitions[word]; found {
+			defined = append(defined, word)
+		}
+	}
+	if len(defined) < quizChoices {
+		return nil, &apiError{
+			Status:  http.StatusBadGateway,
+			Code:    CodeNotEnoughDefinitions,
+			Message: "too few of the picked words have definitions on Wiktionary, try again",
+		}
+	}
+
+	quiz := &QuizResponse{Language: response.Language, Questions: []QuizQuestion{}}
+	for i, word := range defined[:min(questions, len(defined))] {
+		choices := []string{definitions[word]}
+		for _, offset := range rand.Perm(len(defined) - 1)[:quizChoices-1] {
+			choices = append(choices, definitions[defined[(i+1+offset)%len(defined)]])
+		}
+
+		question := QuizQuestion{Word: word, Choices: make([]string, len(choices))}
+		for position, choice := range rand.Perm(len(choices)) {
+			question.Choices[position] = choices[choice]
+			if choice == 0 {
+				question.Answer = position
+			}
+		}
+		quiz.Questions = append(quiz.Questions, question)
+	}
+
+	return quiz, nil
+}
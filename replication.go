@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// walMode opens the database in write-ahead log mode, which external replication tools such as
+// Litestream require.
+var walMode bool
+
+// replicaPath is where a copy of the database is kept, ideally on another disk or a network
+// mount, so that the used words survive the loss of the host. Empty disables it.
+var replicaPath string
+
+// replicaInterval is how often the copy at replicaPath is refreshed.
+var replicaInterval time.Duration
+
+// ReplicationReport is the outcome of the last refresh of the replica.
+type ReplicationReport struct {
+	StartedAt  time.Time `json:"started_at"`
+	DurationMS int64     `json:"duration_ms"`
+	Path       string    `json:"path"`
+	Bytes      int64     `json:"bytes"`
+	Error      string    `json:"error,omitempty"`
+}
+
+var (
+	replicationMu   sync.Mutex
+	lastReplication *ReplicationReport
+)
+
+// replicateDatabase refreshes the replica every interval for as long as the server runs.
+func replicateDatabase(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		logReplication(runReplication(context.Background()))
+	}
+}
+
+func logReplication(report *ReplicationReport) {
+	if report.Error != "" {
+		slog.Error("Database replication failed", "error", report.Error, "path", report.Path)
+		return
+	}
+	slog.Debug("Database replicated", "duration_ms", report.DurationMS, "bytes", report.Bytes, "path", report.Path)
+}
+
+// runReplication writes a consistent snapshot of the database next to the replica with the
+// online backup API, syncs it and renames it over the replica, so that the replica is always
+// a whole database even if the host dies halfway through.
+func runReplication(ctx context.Context) *ReplicationReport {
+	report := &ReplicationReport{StartedAt: time.Now().UTC(), Path: replicaPath}
+	defer func() {
+		report.DurationMS = time.Since(report.StartedAt).Milliseconds()
+
+		replicationMu.Lock()
+		lastReplication = report
+		replicationMu.Unlock()
+	}()
+
+	size, err := writeReplica(ctx, replicaPath)
+	if err != nil {
+		report.Error = err.Error()
+		return report
+	}
+	report.Bytes = size
+
+	return report
+}
+
+func writeReplica(ctx context.Context, path string) (int64, error) {
+	file, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return 0, err
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+
+	if err := copyDatabase(ctx, file.Name(), false); err != nil {
+		return 0, err
+	}
+
+	// The backup API closes the file without syncing it, and CreateTemp makes it private.
+	file, err = os.OpenFile(file.Name(), os.O_RDWR, 0)
+	if err != nil {
+		return 0, err
+	}
+	err = file.Chmod(0o644)
+	if err == nil {
+		err = file.Sync()
+	}
+	info, statErr := file.Stat()
+	file.Close()
+	if err != nil {
+		return 0, err
+	}
+	if statErr != nil {
+		return 0, statErr
+	}
+
+	if err := os.Rename(file.Name(), path); err != nil {
+		return 0, err
+	}
+	if dir, err := os.Open(filepath.Dir(path)); err == nil {
+		dir.Sync()
+		dir.Close()
+	}
+
+	return info.Size(), nil
+}
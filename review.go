@@ -0,0 +1,224 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultEase is the ease factor of a word that hasn't been reviewed yet, and minimumEase
+// the lowest it can fall to, as in SM-2.
+const (
+	defaultEase = 2.5
+	minimumEase = 1.3
+)
+
+// Review is a used word with its spaced repetition schedule. Words that have never been
+// reviewed have no due date and are due immediately.
+type Review struct {
+	Word         string     `json:"word"`
+	Language     string     `json:"language"`
+	ArticleTitle string     `json:"article_title,omitempty"`
+	ArticleURL   string     `json:"article_url,omitempty"`
+	IntervalDays int        `json:"interval_days"`
+	Ease         float64    `json:"ease"`
+	Repetitions  int        `json:"repetitions"`
+	DueAt        *time.Time `json:"due_at,omitempty"`
+}
+
+// ReviewDueResponse is returned by /review/due.
+type ReviewDueResponse struct {
+	Language string   `json:"language"`
+	Reviews  []Review `json:"reviews"`
+}
+
+// ReviewAnswer is the body accepted by /review/answer. Grade rates the recall from
+// 0 (forgotten) to 5 (perfect).
+type ReviewAnswer struct {
+	Word     string `json:"word"`
+	Language string `json:"language"`
+	Grade    int    `json:"grade"`
+}
+
+func initReviewColumns() error {
+	for _, column := range []struct{ name, decl string }{
+		{"review_interval", "INTEGER NOT NULL DEFAULT 0"},
+		{"review_ease", fmt.Sprintf("REAL NOT NULL DEFAULT %v", defaultEase)},
+		{"review_repetitions", "INTEGER NOT NULL DEFAULT 0"},
+		{"review_due", "DATETIME"},
+	} {
+		if err := addColumnIfMissing("used_words", column.name, column.decl); err != nil {
+			return err
+		}
+	}
+
+	_, err := db.Exec(`CREATE INDEX IF NOT EXISTS used_words_language_review_due ON used_words(language, review_due)`)
+	return err
+}
+
+const reviewColumns = `word, language, article_title, article_url, review_interval, review_ease, review_repetitions, review_due`
+
+func scanReview(rows *sql.Rows) (Review, error) {
+	var review Review
+	var title, url sql.NullString
+	var due sql.NullTime
+	err := rows.Scan(&review.Word, &review.Language, &title, &url, &review.IntervalDays, &review.Ease, &review.Repetitions, &due)
+	review.ArticleTitle = title.String
+	review.ArticleURL = url.String
+	if due.Valid {
+		review.DueAt = &due.Time
+	}
+
+	return review, err
+}
+
+// getDueReviews returns up to limit words of the language in the namespace that are due at
+// now, overdue words first and never reviewed words after them.
+func getDueReviews(tx dbtx, namespace string, language string, now time.Time, limit int) ([]Review, error) {
+	rows, err := tx.Query(`SELECT `+reviewColumns+` FROM used_words
+		WHERE namespace=? AND language=? AND (review_due IS NULL OR review_due <= ?)
+		ORDER BY review_due IS NULL, review_due, picked_at, word
+		LIMIT ?`, namespace, language, now.UTC(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reviews := []Review{}
+	for rows.Next() {
+		review, err := scanReview(rows)
+		if err != nil {
+			return nil, err
+		}
+		reviews = append(reviews, review)
+	}
+
+	return reviews, rows.Err()
+}
+
+// schedule applies a graded answer to the review using the SM-2 algorithm.
+func (r *Review) schedule(grade int, now time.Time) {
+	if grade < 3 {
+		r.Repetitions = 0
+		r.IntervalDays = 1
+	} else {
+		switch r.Repetitions {
+		case 0:
+			r.IntervalDays = 1
+		case 1:
+			r.IntervalDays = 6
+		default:
+			r.IntervalDays = int(math.Round(float64(r.IntervalDays) * r.Ease))
+		}
+		r.Repetitions++
+	}
+
+	miss := float64(5 - grade)
+	r.Ease = max(minimumEase, r.Ease+0.1-miss*(0.08+miss*0.02))
+
+	due := now.UTC().AddDate(0, 0, r.IntervalDays)
+	r.DueAt = &due
+}
+
+// answerReview records a graded answer for a word used in the namespace and returns its new
+// schedule.
+func answerReview(namespace string, answer ReviewAnswer, now time.Time) (*Review, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`SELECT `+reviewColumns+` FROM used_words WHERE namespace=? AND word_key=? AND language=?`, namespace, wordKey(answer.Word), answer.Language)
+	if err != nil {
+		return nil, err
+	}
+	if !rows.Next() {
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		return nil, &apiError{
+			Status:  http.StatusNotFound,
+			Code:    CodeNotFound,
+			Message: fmt.Sprintf("%q has not been picked in %s", answer.Word, answer.Language),
+		}
+	}
+	review, err := scanReview(rows)
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	review.schedule(answer.Grade, now)
+	_, err = tx.Exec(`UPDATE used_words SET review_interval=?, review_ease=?, review_repetitions=?, review_due=?
+		WHERE namespace=? AND word_key=? AND language=?`, review.IntervalDays, review.Ease, review.Repetitions, *review.DueAt, namespace, wordKey(review.Word), review.Language)
+	if err != nil {
+		return nil, err
+	}
+
+	return &review, tx.Commit()
+}
+
+func reviewDueHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	language := requestLanguage(r)
+
+	limit := 20
+	if value := query.Get("limit"); value != "" {
+		var err error
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 {
+			writeError(w, invalidParameter("limit", "limit must be a positive integer"))
+			return
+		}
+	}
+
+	reviews, err := getDueReviews(db, requestNamespace(r.Context()), language, time.Now(), limit)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ReviewDueResponse{Language: language, Reviews: reviews})
+}
+
+func reviewAnswerHandler(w http.ResponseWriter, r *http.Request) {
+	var answer ReviewAnswer
+	if err := json.NewDecoder(r.Body).Decode(&answer); err != nil {
+		writeError(w, invalidParameter("body", "invalid JSON body: %v", err))
+		return
+	}
+	answer.Word = strings.TrimSpace(answer.Word)
+	if answer.Language == "" {
+		answer.Language = preferredLanguage(r.Header.Get("Accept-Language"))
+	}
+	if answer.Word == "" {
+		writeError(w, invalidParameter("word", "word is required"))
+		return
+	}
+	if answer.Grade < 0 || answer.Grade > 5 {
+		writeError(w, invalidParameter("grade", "grade must be between 0 and 5"))
+		return
+	}
+
+	var review *Review
+	err := retryBusy(r.Context(), func() (err error) {
+		review, err = answerReview(requestNamespace(r.Context()), answer, time.Now())
+		return err
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(review)
+}
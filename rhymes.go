@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// RhymeGroup is a set of picked words sharing an ending.
+type RhymeGroup struct {
+	Rhyme string   `json:"rhyme"`
+	Words []string `json:"words"`
+}
+
+// RhymesResponse is returned by /rhymes.
+type RhymesResponse struct {
+	Language string       `json:"language"`
+	Groups   []RhymeGroup `json:"groups"`
+}
+
+const vowels = "aeiouyàâäéèêëîïôöùûüÿœæ"
+
+// silentEndings are trimmed from the end of words before finding their rhyme, since they
+// aren't pronounced.
+var silentEndings = map[string]string{
+	"fr": "estxd",
+}
+
+// RhymeKey returns the ending a word rhymes on: its last vowel group and the consonants after
+// it, ignoring silent endings. Words without a vowel have no key.
+func RhymeKey(word string, language string) string {
+	word = strings.ToLower(word)
+	if silent := silentEndings[language]; silent != "" {
+		if trimmed := strings.TrimRight(word, silent); strings.ContainsAny(trimmed, vowels) {
+			word = trimmed
+		}
+	}
+
+	runes := []rune(word)
+	end := len(runes)
+	for end > 0 && !strings.ContainsRune(vowels, runes[end-1]) {
+		end--
+	}
+	if end == 0 {
+		return ""
+	}
+	start := end
+	for start > 0 && strings.ContainsRune(vowels, runes[start-1]) {
+		start--
+	}
+
+	return string(runes[start:])
+}
+
+// rhymesHandler picks a batch of words and groups those that rhyme, largest groups first.
+// The words are not marked as used.
+func rhymesHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	language := requestLanguage(r)
+
+	count := min(50, maxCount)
+	if value := query.Get("count"); value != "" {
+		var err error
+		count, err = strconv.Atoi(value)
+		if err != nil || count < 1 || count > maxCount {
+			writeError(w, invalidParameter("count", "count must be between 1 and %d", maxCount))
+			return
+		}
+	}
+
+	rhymes, err := buildRhymes(r.Context(), requestNamespace(r.Context()), language, count)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rhymes)
+}
+
+func buildRhymes(ctx context.Context, namespace string, language string, count int) (*RhymesResponse, error) {
+	response, err := pick(ctx, pickOptions{
+		Source:    defaultWordSource,
+		Namespace: namespace,
+		Language:  language,
+		Count:     count,
+		Articles:  1,
+		NgramSize: 1,
+		Safe:      safeByDefault,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	byRhyme := make(map[string][]string)
+	for _, word := range response.Words {
+		if key := RhymeKey(word, response.Language); key != "" {
+			byRhyme[key] = append(byRhyme[key], word)
+		}
+	}
+
+	rhymes := &RhymesResponse{Language: response.Language, Groups: []RhymeGroup{}}
+	for key, words := range byRhyme {
+		if len(words) > 1 {
+			sort.Strings(words)
+			rhymes.Groups = append(rhymes.Groups, RhymeGroup{Rhyme: key, Words: words})
+		}
+	}
+	sort.Slice(rhymes.Groups, func(i, j int) bool {
+		if len(rhymes.Groups[i].Words) != len(rhymes.Groups[j].Words) {
+			return len(rhymes.Groups[i].Words) > len(rhymes.Groups[j].Words)
+		}
+		return rhymes.Groups[i].Rhyme < rhymes.Groups[j].Rhyme
+	})
+
+	return rhymes, nil
+}
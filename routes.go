@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// apiVersion is the current version of the API. It prefixes the versioned routes and is
+// reported in pick responses.
+const apiVersion = "1"
+
+// rateLimitPerMinute is how many requests a client IP may make a minute. Zero disables the limit.
+var rateLimitPerMinute int
+
+// newHandler builds the server's handler: the routes wrapped in the middleware every
+// request passes through.
+func newHandler() http.Handler {
+	mux := http.NewServeMux()
+	registerRoutes(mux)
+
+	return Chain(muxErrors(mux), assignRequestID, recoverPanics, traceRequests, logRequests, rateLimit(requestLimiter), assignNamespace)
+}
+
+// registerRoutes adds every endpoint under /v1 and, for existing clients, at its
+// unversioned path. Routes are method patterns, so the mux answers other methods with 405.
+func registerRoutes(mux *http.ServeMux) {
+	blocklist := Chain(wordListHandler(blocklistTable), requireAdmin)
+	allowlist := Chain(wordListHandler(allowlistTable), requireAdmin)
+
+	routes := map[string]http.Handler{
+		"GET /pick":                     Chain(http.HandlerFunc(pickHandler), requireAPIKey),
+		"GET /pick/longpoll":            Chain(http.HandlerFunc(longpollHandler), requireAPIKey),
+		"POST /pick":                    Chain(http.HandlerFunc(pickBodyHandler), requireAPIKey),
+		"POST /extract":                 Chain(http.HandlerFunc(extractHandler), requireAPIKey),
+		"GET /history":                  Chain(http.HandlerFunc(historyHandler), requireAPIKey),
+		"POST /history/undo":            Chain(http.HandlerFunc(undoHandler), requireAPIKey),
+		"GET /languages/{code}/history": Chain(http.HandlerFunc(historyHandler), requireAPIKey),
+		"GET /stats":                    Chain(http.HandlerFunc(statsHandler), requireAPIKey),
+		"GET /analytics/top":            Chain(http.HandlerFunc(topWordsHandler), requireAPIKey),
+		"GET /quiz":                     Chain(http.HandlerFunc(quizHandler), requireAPIKey),
+		"GET /readyz":                   http.HandlerFunc(readyHandler),
+		"GET /cloud.svg":                Chain(http.HandlerFunc(cloudHandler), requireAPIKey),
+		"GET /rhymes":                   Chain(http.HandlerFunc(rhymesHandler), requireAPIKey),
+		"GET /export/anki":              Chain(http.HandlerFunc(ankiExportHandler), requireAPIKey),
+		"GET /review/due":               Chain(http.HandlerFunc(reviewDueHandler), requireAPIKey),
+		"POST /review/answer":           Chain(http.HandlerFunc(reviewAnswerHandler), requireAPIKey),
+		"POST /auth/token":              Chain(http.HandlerFunc(tokenHandler), requireTokens, requireAPIKey),
+		"POST /auth/refresh":            Chain(http.HandlerFunc(refreshHandler), requireTokens),
+		"GET /auth/wikimedia/login":     Chain(http.HandlerFunc(wikimediaLoginHandler), requireOAuth),
+		"GET /auth/wikimedia/callback":  Chain(http.HandlerFunc(wikimediaCallbackHandler), requireOAuth),
+		"GET /admin/blocklist":          blocklist,
+		"POST /admin/blocklist":         blocklist,
+		"DELETE /admin/blocklist":       blocklist,
+		"GET /admin/allowlist":          allowlist,
+		"POST /admin/allowlist":         allowlist,
+		"DELETE /admin/allowlist":       allowlist,
+		"GET /admin/audit":              Chain(http.HandlerFunc(auditHandler), requireAdmin),
+		"GET /admin/stats":              Chain(http.HandlerFunc(adminStatsHandler), requireAdmin),
+		"GET /admin/backup":             Chain(http.HandlerFunc(backupHandler), requireAdmin),
+		"POST /admin/restore":           Chain(http.HandlerFunc(restoreHandler), requireAdmin),
+	}
+
+	for pattern, handler := range routes {
+		method, path, _ := strings.Cut(pattern, " ")
+		mux.Handle(method+" /v"+apiVersion+path, handler)
+		mux.Handle(pattern, handler)
+	}
+
+	// The demo pages aren't part of the API, so they have no versioned path.
+	mux.Handle("GET /{$}", uiPage("index.html"))
+	mux.Handle("GET /playground", uiPage("playground.html"))
+}
+
+// muxErrors reports the mux's own 404 and 405 responses in the JSON error envelope instead of
+// plain text. The mux still decides which applies and sets the Allow header of a 405.
+func muxErrors(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, pattern := mux.Handler(r); pattern != "" {
+			mux.ServeHTTP(w, r)
+			return
+		}
+
+		mux.ServeHTTP(&muxErrorWriter{ResponseWriter: w, r: r}, r)
+	})
+}
+
+// muxErrorWriter replaces a 404 or 405 written through it with a JSON error and drops the
+// plain text body that follows.
+type muxErrorWriter struct {
+	http.ResponseWriter
+	r        *http.Request
+	replaced bool
+}
+
+func (w *muxErrorWriter) WriteHeader(status int) {
+	switch status {
+	case http.StatusNotFound:
+		w.replaced = true
+		notFoundHandler(w.ResponseWriter, w.r)
+	case http.StatusMethodNotAllowed:
+		w.replaced = true
+		allow := w.Header().Get("Allow")
+		writeError(w.ResponseWriter, &apiError{
+			Status:  http.StatusMethodNotAllowed,
+			Code:    CodeMethodNotAllowed,
+			Message: fmt.Sprintf("%s is not supported, use %s", w.r.Method, allow),
+			Details: map[string]any{"allow": strings.Split(allow, ", ")},
+		})
+	default:
+		w.ResponseWriter.WriteHeader(status)
+	}
+}
+
+func (w *muxErrorWriter) Write(b []byte) (int, error) {
+	if w.replaced {
+		return len(b), nil
+	}
+
+	return w.ResponseWriter.Write(b)
+}
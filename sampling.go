@@ -0,0 +1,159 @@
+package main
+
+import (
+	"errors"
+	"maps"
+	"math"
+	"math/rand"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Sampling biases accepted by `bias`. Without one every candidate word is equally likely.
+const (
+	biasRare   = "rare"
+	biasCommon = "common"
+)
+
+// defaultLengthWeight is how many times likelier than others words of the preferred length
+// are when the pick doesn't set `length_weight`, and maxLengthWeight the most it may set.
+const (
+	defaultLengthWeight = 4
+	maxLengthWeight     = 100
+)
+
+// sample picks n distinct words of the pool that aren't in used or already picked with the
+// pick's cursor, uniformly at random unless the pick asks for weighted sampling.
+func (p *candidatePool) sample(n int, used map[string]struct{}) []string {
+	if len(p.cursorWords) > 0 {
+		used = maps.Clone(used)
+		maps.Copy(used, p.cursorWords)
+	}
+
+	if p.opts.Bias == "" && p.opts.PreferLength.Max == 0 {
+		return PickRandomUniqueWords(p.Words, n, used)
+	}
+
+	return PickWeightedUniqueWords(p.Words, n, used, p.weight)
+}
+
+// weight is how likely a word is to be sampled relative to the others. With `bias=common`
+// it grows with the word's Zipf frequency and with `bias=rare` it shrinks, so a word of the
+// rarest kind is nine times as likely as "the" to be picked, or the other way round. Words
+// of the `prefer_length` are `length_weight` times as likely again.
+func (p *candidatePool) weight(word string) float64 {
+	weight := 1.0
+	switch p.opts.Bias {
+	case biasRare:
+		weight = 1 / (1 + phraseZipfFrequency(word, p.Language))
+	case biasCommon:
+		weight = 1 + phraseZipfFrequency(word, p.Language)
+	}
+
+	if p.opts.PreferLength.contains(letterCount(word)) {
+		weight *= p.opts.LengthWeight
+	}
+
+	return weight
+}
+
+// lengthRange is an inclusive range of word lengths in letters. The zero range holds none.
+type lengthRange struct {
+	Min, Max int
+}
+
+func (r lengthRange) contains(length int) bool {
+	return length >= r.Min && length <= r.Max
+}
+
+// parseLengthRange reads a length like "7" or a range like "6-10".
+func parseLengthRange(text string) (lengthRange, error) {
+	low, high, isRange := strings.Cut(text, "-")
+	if !isRange {
+		high = low
+	}
+
+	minimum, err := strconv.Atoi(low)
+	if err != nil {
+		return lengthRange{}, err
+	}
+	maximum, err := strconv.Atoi(high)
+	if err != nil {
+		return lengthRange{}, err
+	}
+	if minimum < 1 || maximum < minimum {
+		return lengthRange{}, errors.New("lengths must be positive and ascending")
+	}
+
+	return lengthRange{Min: minimum, Max: maximum}, nil
+}
+
+// letterCount counts the letters of a word, or of all the words of a phrase.
+func letterCount(word string) int {
+	count := 0
+	for _, r := range word {
+		if !unicode.IsSpace(r) {
+			count++
+		}
+	}
+
+	return count
+}
+
+// phraseZipfFrequency is the Zipf frequency of the rarest word of a phrase.
+func phraseZipfFrequency(phrase string, language string) float64 {
+	zipf := math.Inf(1)
+	for _, word := range strings.Fields(phrase) {
+		zipf = min(zipf, ZipfFrequency(word, language))
+	}
+	if math.IsInf(zipf, 1) {
+		return 0
+	}
+
+	return zipf
+}
+
+// PickWeightedUniqueWords returns n distinct words from the input slice whose keys are not in
+// usedBefore, drawn without replacement with probability proportional to their weight. If
+// there are fewer such words, it returns all of them. Each word gets the key u^(1/weight)
+// for a uniform random u, and the words with the largest keys are picked (Efraimidis and
+// Spirakis), which needs a single pass.
+func PickWeightedUniqueWords(words []string, n int, usedBefore map[string]struct{}, weight func(string) float64) []string {
+	type keyed struct {
+		word string
+		key  float64
+	}
+
+	var candidates []keyed
+	seen := make(map[string]struct{})
+	for _, word := range words {
+		key := wordKey(word)
+		if _, used := usedBefore[key]; used {
+			continue
+		}
+		if _, found := seen[key]; found {
+			continue
+		}
+		seen[key] = struct{}{}
+		candidates = append(candidates, keyed{word: word, key: math.Pow(rand.Float64(), 1/weight(word))})
+	}
+
+	slices.SortFunc(candidates, func(a, b keyed) int {
+		switch {
+		case a.key > b.key:
+			return -1
+		case a.key < b.key:
+			return 1
+		}
+		return 0
+	})
+
+	picked := make([]string, 0, min(n, len(candidates)))
+	for _, candidate := range candidates[:min(n, len(candidates))] {
+		picked = append(picked, candidate.word)
+	}
+
+	return picked
+}
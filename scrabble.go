@@ -0,0 +1,54 @@
+package main
+
+import "strings"
+
+// scrabbleLetterValues are the tile values of each language's Scrabble edition.
+var scrabbleLetterValues = map[string]map[rune]int{
+	"en": letterValues(map[int]string{
+		1: "aeioulnstr", 2: "dg", 3: "bcmp", 4: "fhvwy", 5: "k", 8: "jx", 10: "qz",
+	}),
+	"fr": letterValues(map[int]string{
+		1: "aeilnorstu", 2: "dgm", 3: "bcp", 4: "fhv", 8: "jq", 10: "kwxyz",
+	}),
+	"de": letterValues(map[int]string{
+		1: "ensirtuad", 2: "hglo", 3: "mbwz", 4: "cfkp", 6: "äjüv", 8: "öx", 10: "qy",
+	}),
+}
+
+func letterValues(lettersByValue map[int]string) map[rune]int {
+	values := make(map[rune]int)
+	for value, letters := range lettersByValue {
+		for _, letter := range letters {
+			values[letter] = value
+		}
+	}
+
+	return values
+}
+
+// scrabbleSpellings replaces letters that have no tile of their own with the tiles they
+// are played as.
+var scrabbleSpellings = map[string]*strings.Replacer{
+	"fr": strings.NewReplacer(
+		"à", "a", "â", "a", "ä", "a", "ç", "c", "é", "e", "è", "e", "ê", "e", "ë", "e",
+		"î", "i", "ï", "i", "ô", "o", "ö", "o", "ù", "u", "û", "u", "ü", "u", "ÿ", "y",
+		"œ", "oe", "æ", "ae",
+	),
+	"de": strings.NewReplacer("ß", "ss"),
+}
+
+// ScrabbleScore adds up the tile values of a word in the language's Scrabble edition.
+// Letters without a tile count nothing; phrases score the sum of their words.
+func ScrabbleScore(word string, language string) int {
+	values := scrabbleLetterValues[language]
+	if spelling, found := scrabbleSpellings[language]; found {
+		word = spelling.Replace(word)
+	}
+
+	score := 0
+	for _, letter := range word {
+		score += values[letter]
+	}
+
+	return score
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+)
+
+// languageScripts are the scripts each language is written in. Words with letters outside
+// them, such as Latin names in a Russian article, are dropped from the pool.
+var languageScripts = map[string][]*unicode.RangeTable{
+	"en": {unicode.Latin},
+	"fr": {unicode.Latin},
+	"de": {unicode.Latin},
+	"es": {unicode.Latin},
+	"it": {unicode.Latin},
+	"nl": {unicode.Latin},
+	"pl": {unicode.Latin},
+	"pt": {unicode.Latin},
+	"sv": {unicode.Latin},
+	"tr": {unicode.Latin},
+	"ru": {unicode.Cyrillic},
+	"uk": {unicode.Cyrillic},
+	"bg": {unicode.Cyrillic},
+	"sr": {unicode.Cyrillic, unicode.Latin},
+	"el": {unicode.Greek},
+	"ar": {unicode.Arabic},
+	"fa": {unicode.Arabic},
+	"he": {unicode.Hebrew},
+	"hi": {unicode.Devanagari},
+	"ko": {unicode.Hangul},
+	"ja": {unicode.Han, unicode.Hiragana, unicode.Katakana},
+	"zh": {unicode.Han},
+}
+
+// inScripts reports whether every letter of the word belongs to one of the scripts or to
+// no script in particular, like the Japanese long vowel mark.
+func inScripts(word string, scripts []*unicode.RangeTable) bool {
+	for _, r := range word {
+		if unicode.IsLetter(r) && !unicode.In(r, scripts...) && !unicode.In(r, unicode.Common, unicode.Inherited) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// requestedScripts returns the Unicode scripts named in a comma-separated `script` parameter,
+// such as "latin" or "cyrillic,latin". Names are matched regardless of case; ok is false if
+// one isn't a script.
+func requestedScripts(names string) (scripts []*unicode.RangeTable, ok bool) {
+	for _, name := range strings.Split(names, ",") {
+		table, found := scriptTable(strings.TrimSpace(name))
+		if !found {
+			return nil, false
+		}
+		scripts = append(scripts, table)
+	}
+
+	return scripts, true
+}
+
+func scriptTable(name string) (*unicode.RangeTable, bool) {
+	for script, table := range unicode.Scripts {
+		if strings.EqualFold(script, name) {
+			return table, true
+		}
+	}
+
+	return nil, false
+}
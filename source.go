@@ -0,0 +1,25 @@
+package main
+
+import "context"
+
+// WordSource supplies the text that words are picked from.
+type WordSource interface {
+	// Supports reports whether the source has text in the language.
+	Supports(language string) bool
+
+	// Fetch returns a batch of text in the language, such as one random article.
+	Fetch(ctx context.Context, language string) (*Article, error)
+}
+
+// defaultWordSource is used when a pick doesn't name a source. Set it to "dump" to serve
+// picks from an imported dump without access to Wikipedia.
+var defaultWordSource = "wikipedia"
+
+// wordSources holds the sources a pick can select with `source=`.
+var wordSources = map[string]WordSource{}
+
+// RegisterWordSource makes a source available under the name. Sources are registered from
+// init functions, so it is not safe to call once the server is running.
+func RegisterWordSource(name string, source WordSource) {
+	wordSources[name] = source
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// LanguageStats summarizes the words served for a language, returned by /stats.
+type LanguageStats struct {
+	Language               string  `json:"language"`
+	TotalWords             int     `json:"total_words"`
+	WordsToday             int     `json:"words_today"`
+	WordsThisWeek          int     `json:"words_this_week"`
+	Articles               int     `json:"articles"`
+	AverageWordsPerArticle float64 `json:"average_words_per_article"`
+}
+
+// initStatsIndexes indexes the used words the way the stats query them, by namespace and
+// language. The index on language alone predates namespaces and is dropped.
+func initStatsIndexes() error {
+	for _, statement := range []string{
+		`CREATE INDEX IF NOT EXISTS used_words_namespace_language_picked_at ON used_words(namespace, language, picked_at)`,
+		`DROP INDEX IF EXISTS used_words_language_picked_at`,
+	} {
+		if _, err := db.Exec(statement); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getLanguageStats counts the words served for the language in the namespace. Days and weeks
+// start at midnight UTC, weeks on Monday. Articles are told apart by URL, or by title for
+// sources without one, such as dumps, ZIM archives and the corpus.
+func getLanguageStats(tx dbtx, namespace string, language string, now time.Time) (*LanguageStats, error) {
+	now = now.UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	week := today.AddDate(0, 0, -((int(today.Weekday()) + 6) % 7))
+
+	stats := &LanguageStats{Language: language}
+	rows, err := tx.Query(`SELECT
+			COUNT(*),
+			COUNT(CASE WHEN picked_at >= ? THEN 1 END),
+			COUNT(CASE WHEN picked_at >= ? THEN 1 END),
+			COUNT(DISTINCT COALESCE(NULLIF(article_url, ''), NULLIF(article_title, ''))),
+			COUNT(COALESCE(NULLIF(article_url, ''), NULLIF(article_title, '')))
+		FROM used_words WHERE namespace=? AND language=?`, today, week, namespace, language)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var withArticle int
+	if rows.Next() {
+		if err := rows.Scan(&stats.TotalWords, &stats.WordsToday, &stats.WordsThisWeek, &stats.Articles, &withArticle); err != nil {
+			return nil, err
+		}
+	}
+	if stats.Articles > 0 {
+		stats.AverageWordsPerArticle = float64(withArticle) / float64(stats.Articles)
+	}
+
+	return stats, rows.Err()
+}
+
+func statsHandler(w http.ResponseWriter, r *http.Request) {
+	language := requestLanguage(r)
+
+	stats, err := getLanguageStats(db, requestNamespace(r.Context()), language, time.Now())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
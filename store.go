@@ -0,0 +1,412 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	mathrand "math/rand/v2"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// The store opens transactions with BEGIN IMMEDIATE so that concurrent picks for the same
+// language serialize on the write lock instead of both reading the same unused words.
+// busy_timeout makes a waiting pick block for the lock rather than fail with SQLITE_BUSY.
+// Times are written in a format SQLite's date functions understand.
+const dataSourceName = "file:words.db?_txlock=immediate&_pragma=busy_timeout(5000)&_time_format=sqlite"
+
+var db *sql.DB
+
+// dbtx is satisfied by both *sql.DB and *sql.Tx.
+type dbtx interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+	Prepare(query string) (*sql.Stmt, error)
+}
+
+// maxBusyRetries bounds how often a write is retried after finding the database busy or locked.
+const maxBusyRetries = 4
+
+// busyRetryDelay is the backoff before the first retry of a busy write; it doubles with each.
+const busyRetryDelay = 50 * time.Millisecond
+
+// retryBusy runs the write, retrying it with jittered exponential backoff while SQLite reports
+// the database busy or locked, as it does when busy_timeout runs out behind a VACUUM, a
+// restore or a burst of concurrent picks. The write must be a whole transaction, since a busy
+// one is rolled back. A write that stays busy fails with SERVER_BUSY.
+func retryBusy(ctx context.Context, write func() error) error {
+	delay := busyRetryDelay
+	for attempt := 0; ; attempt++ {
+		err := write()
+		if !isBusy(err) {
+			return err
+		}
+		if attempt == maxBusyRetries {
+			return &apiError{
+				Status:  http.StatusServiceUnavailable,
+				Code:    CodeServerBusy,
+				Message: "the word database is busy, try again",
+				Details: map[string]any{"retry_after_seconds": 1},
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay/2 + mathrand.N(delay)):
+		}
+		delay *= 2
+	}
+}
+
+// retryTx runs the write in a transaction, which retryBusy retries while the database is busy.
+func retryTx(ctx context.Context, write func(tx *sql.Tx) error) error {
+	return retryBusy(ctx, func() error {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		if err := write(tx); err != nil {
+			return err
+		}
+		return tx.Commit()
+	})
+}
+
+// isBusy reports whether the error is SQLITE_BUSY or SQLITE_LOCKED, including their extended codes.
+func isBusy(err error) bool {
+	var sqliteErr *sqlite.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+	code := sqliteErr.Code() & 0xff
+	return code == sqlite3.SQLITE_BUSY || code == sqlite3.SQLITE_LOCKED
+}
+
+func initDB() error {
+	var err error
+	dsn := dataSourceName
+	if walMode {
+		dsn += "&_pragma=journal_mode(WAL)"
+	}
+	db, err = sql.Open("sqlite", dsn)
+	if err != nil {
+		return err
+	}
+
+	return initSchema()
+}
+
+// initSchema creates the tables and upgrades those of older databases, such as a restored
+// snapshot.
+func initSchema() error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS used_words (namespace TEXT NOT NULL DEFAULT '',word TEXT,language TEXT,PRIMARY KEY(namespace, word, language))`)
+	if err != nil {
+		return err
+	}
+	if err := addNamespaceKey("used_words"); err != nil {
+		return err
+	}
+
+	// Provenance columns were added after the table was first shipped, so existing
+	// databases are upgraded in place.
+	for _, column := range []struct{ name, decl string }{
+		{"article_title", "TEXT"},
+		{"article_url", "TEXT"},
+		{"picked_at", "DATETIME"},
+		{"picked_by", "TEXT"},
+		{"pick_id", "TEXT"},
+	} {
+		if err := addColumnIfMissing("used_words", column.name, column.decl); err != nil {
+			return err
+		}
+	}
+
+	if err := initWordKeys(); err != nil {
+		return err
+	}
+	if err := initStatsIndexes(); err != nil {
+		return err
+	}
+	if err := initPickIndex(); err != nil {
+		return err
+	}
+	if err := initDumpTables(); err != nil {
+		return err
+	}
+	if err := initReviewColumns(); err != nil {
+		return err
+	}
+	if err := initAuditTable(); err != nil {
+		return err
+	}
+	if err := initAnalyticsTable(); err != nil {
+		return err
+	}
+	if err := initCursorTables(); err != nil {
+		return err
+	}
+	if err := initIdempotencyTable(); err != nil {
+		return err
+	}
+
+	return initWordListTables()
+}
+
+// addColumnIfMissing adds a column to an existing table unless it is already there.
+func addColumnIfMissing(table, column, decl string) error {
+	columns, err := tableColumns(table)
+	if err != nil {
+		return err
+	}
+	for _, existing := range columns {
+		if existing.name == column {
+			return nil
+		}
+	}
+
+	_, err = db.Exec("ALTER TABLE " + table + " ADD COLUMN " + column + " " + decl)
+	return err
+}
+
+// tableColumn is a column of a table and its declaration, without any key constraint.
+type tableColumn struct{ name, decl string }
+
+func tableColumns(table string) ([]tableColumn, error) {
+	rows, err := db.Query("SELECT name, type, \"notnull\", dflt_value FROM pragma_table_info(?)", table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columns []tableColumn
+	for rows.Next() {
+		var column tableColumn
+		var notNull bool
+		var defaultValue sql.NullString
+		if err := rows.Scan(&column.name, &column.decl, &notNull, &defaultValue); err != nil {
+			return nil, err
+		}
+		if notNull {
+			column.decl += " NOT NULL"
+		}
+		if defaultValue.Valid {
+			column.decl += " DEFAULT " + defaultValue.String
+		}
+		columns = append(columns, column)
+	}
+
+	return columns, rows.Err()
+}
+
+// addNamespaceKey upgrades a (word, language) table from before namespaces to one keyed by
+// (namespace, word, language), putting its rows in the default namespace. SQLite can't change
+// a primary key in place, so the table is copied. Its indexes go with the old table and are
+// recreated by initSchema.
+func addNamespaceKey(table string) error {
+	columns, err := tableColumns(table)
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(columns))
+	decls := make([]string, 0, len(columns))
+	for _, column := range columns {
+		if column.name == "namespace" {
+			return nil
+		}
+		names = append(names, column.name)
+		decls = append(decls, strings.TrimSpace(column.name+" "+column.decl))
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, statement := range []string{
+		`CREATE TABLE ` + table + `_namespaced (namespace TEXT NOT NULL DEFAULT '',` + strings.Join(decls, ",") + `,PRIMARY KEY(namespace, word, language))`,
+		`INSERT INTO ` + table + `_namespaced(` + strings.Join(names, ",") + `) SELECT ` + strings.Join(names, ",") + ` FROM ` + table,
+		`DROP TABLE ` + table,
+		`ALTER TABLE ` + table + `_namespaced RENAME TO ` + table,
+	} {
+		if _, err := tx.Exec(statement); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// wordKey is the form a used word is stored and compared under, so that words differing only
+// in case or Unicode normalization, like "Berlin" and "berlin", are one word. The word column
+// keeps the form that was picked.
+func wordKey(word string) string {
+	return norm.NFC.String(cases.Fold().String(word))
+}
+
+// initWordKeys adds the word_key column to used_words and fills it in for words stored before
+// it existed. Words that turn out to share a key are merged into the first one stored.
+func initWordKeys() error {
+	if err := addColumnIfMissing("used_words", "word_key", "TEXT"); err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query("SELECT rowid, word FROM used_words WHERE word_key IS NULL")
+	if err != nil {
+		return err
+	}
+	keys := make(map[int64]string)
+	for rows.Next() {
+		var id int64
+		var word string
+		if err := rows.Scan(&id, &word); err != nil {
+			rows.Close()
+			return err
+		}
+		keys[id] = wordKey(word)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for id, key := range keys {
+		if _, err := tx.Exec("UPDATE used_words SET word_key=? WHERE rowid=?", key, id); err != nil {
+			return err
+		}
+	}
+	if len(keys) > 0 {
+		_, err := tx.Exec(`DELETE FROM used_words WHERE rowid NOT IN
+			(SELECT MIN(rowid) FROM used_words GROUP BY namespace, language, word_key)`)
+		if err != nil {
+			return err
+		}
+	}
+	_, err = tx.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS used_words_namespace_language_word_key ON used_words(namespace, language, word_key)`)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// storeBatchSize is how many used words one INSERT stores, keeping its bound parameters well
+// below SQLite's limit.
+const storeBatchSize = 100
+
+// storeUsedWords marks the words as used in the namespace, recording the article each was
+// picked from and the user who picked it. The words share a pick ID, so the batch can be undone.
+// They are inserted storeBatchSize rows at a time.
+func storeUsedWords(tx dbtx, namespace string, words []string, language string, sources map[string]*Article, user string) error {
+	const columns = "(?,?,?,?,?,?,?,?,?)"
+
+	pickedAt := time.Now().UTC()
+	pickID := rand.Text()
+	for batch := range slices.Chunk(words, storeBatchSize) {
+		args := make([]any, 0, 9*len(batch))
+		for _, word := range batch {
+			var title, url string
+			if source := sources[word]; source != nil {
+				title, url = source.Title, source.URL
+			}
+			args = append(args, namespace, word, wordKey(word), language, title, url, pickedAt, user, pickID)
+		}
+
+		values := strings.Repeat(columns+",", len(batch)-1) + columns
+		if _, err := tx.Exec("INSERT OR IGNORE INTO used_words(namespace,word,word_key,language,article_title,article_url,picked_at,picked_by,pick_id) VALUES "+values, args...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getUsedWords returns the keys of the words used in the namespace, as given by wordKey. An
+// empty language returns the words used in every language.
+func getUsedWords(tx dbtx, namespace string, language string) (map[string]struct{}, error) {
+	rows, err := tx.Query("SELECT DISTINCT word_key FROM used_words WHERE namespace=? AND (?='' OR language=?)", namespace, language, language)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	used := make(map[string]struct{})
+	for rows.Next() {
+		var word string
+		if err := rows.Scan(&word); err != nil {
+			return nil, err
+		}
+		used[word] = struct{}{}
+	}
+	return used, rows.Err()
+}
+
+// pickAndStore picks count unused words from the pool and marks them as used, reading and
+// writing used_words in a single transaction that is retried while the database is busy.
+func pickAndStore(ctx context.Context, pool *candidatePool, count int) (words []string, err error) {
+	ctx, span := tracer.Start(ctx, "store.pick")
+	defer func() { endSpan(span, err) }()
+
+	err = retryBusy(ctx, func() error {
+		words, err = pickAndStoreOnce(ctx, pool, count)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return words, nil
+}
+
+// pickAndStoreOnce runs the transaction of pickAndStore.
+func pickAndStoreOnce(ctx context.Context, pool *candidatePool, count int) ([]string, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	_, readSpan := tracer.Start(ctx, "store.read_used")
+	usedBefore, err := getUsedWords(tx, pool.opts.Namespace, pool.usedLanguage())
+	endSpan(readSpan, err)
+	if err != nil {
+		return nil, err
+	}
+	pool.UsedBefore = usedBefore
+
+	_, sampleSpan := tracer.Start(ctx, "sample")
+	start := time.Now()
+	words := pool.sample(count, usedBefore)
+	pool.timing.sample = time.Since(start)
+	sampleSpan.End()
+
+	_, writeSpan := tracer.Start(ctx, "store.write_used")
+	err = storeUsedWords(tx, pool.opts.Namespace, words, pool.Language, pool.Sources, requestUser(ctx))
+	if err == nil && pool.opts.Cursor != "" {
+		err = recordCursorWords(tx, pool.opts.Cursor, words)
+	}
+	endSpan(writeSpan, err)
+	if err != nil {
+		return nil, err
+	}
+
+	return words, tx.Commit()
+}
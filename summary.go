@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// maxLiteCount is the largest `count` a `lite=true` pick may ask for, since a summary holds
+// only the lead of an article.
+const maxLiteCount = 20
+
+// summaryResponse is the part of a REST page/summary response we read.
+type summaryResponse struct {
+	Title         string `json:"title"`
+	Extract       string `json:"extract"`
+	OriginalImage struct {
+		Source string `json:"source"`
+	} `json:"originalimage"`
+	ContentURLs struct {
+		Desktop struct {
+			Page string `json:"page"`
+		} `json:"desktop"`
+	} `json:"content_urls"`
+}
+
+// summaryURL returns the REST endpoint redirecting to the summary of a random page of the
+// language's edition.
+func (s wikimediaSource) summaryURL(language string) (string, error) {
+	random, err := url.Parse(s.randomPageURL(language))
+	if err != nil {
+		return "", err
+	}
+
+	return random.Scheme + "://" + random.Host + "/api/rest_v1/page/random/summary", nil
+}
+
+// fetchSummary fetches the lead paragraph of a random page from the REST API, a few
+// kilobytes instead of a whole article. The returned article has no paragraphs if the
+// edition has no REST API.
+func (s wikimediaSource) fetchSummary(ctx context.Context, language string) (*Article, error) {
+	endpoint, err := s.summaryURL(language)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := upstreamClient.Do(req)
+	if err != nil {
+		return nil, upstreamError(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return &Article{}, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, upstreamError(fmt.Errorf("%s returned %s", s.project, resp.Status))
+	}
+
+	var body summaryResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, upstreamError(fmt.Errorf("failed to decode summary: %w", err))
+	}
+
+	article := &Article{Title: body.Title, URL: body.ContentURLs.Desktop.Page, Image: body.OriginalImage.Source}
+	article.Paragraphs, article.Sections = extractParagraphs(body.Extract)
+
+	return article, nil
+}
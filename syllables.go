@@ -0,0 +1,44 @@
+package main
+
+import "strings"
+
+// Syllables estimates the number of syllables in a word by counting its groups of vowels,
+// after dropping the final e that English and French don't pronounce. Phrases count the
+// syllables of all their words.
+func Syllables(word string, language string) int {
+	total := 0
+	for _, part := range strings.Fields(strings.ToLower(word)) {
+		total += wordSyllables(part, language)
+	}
+
+	return total
+}
+
+func wordSyllables(word string, language string) int {
+	switch language {
+	case "en":
+		// "make" has one syllable, but "table" has two.
+		if strings.HasSuffix(word, "e") && !strings.HasSuffix(word, "le") && !strings.HasSuffix(word, "ee") {
+			word = strings.TrimSuffix(word, "e")
+		}
+	case "fr":
+		for _, silent := range []string{"es", "e"} {
+			if trimmed, found := strings.CutSuffix(word, silent); found && strings.ContainsAny(trimmed, vowels) {
+				word = trimmed
+				break
+			}
+		}
+	}
+
+	count := 0
+	inVowels := false
+	for _, r := range word {
+		isVowel := strings.ContainsRune(vowels, r)
+		if isVowel && !inVowels {
+			count++
+		}
+		inVowels = isVowel
+	}
+
+	return max(count, 1)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// maxLag is sent as `maxlag` with action API requests: Wikimedia refuses them while its
+// replica databases lag further behind than this many seconds, asking bots to back off.
+const maxLag = "5"
+
+// defaultThrottlePause is how long a host is paused when it throttles us without saying for
+// how long.
+const defaultThrottlePause = 30 * time.Second
+
+// throttleError is returned for requests to a host that has asked us, with a 429 or a maxlag
+// error, to wait until a time.
+type throttleError struct {
+	host  string
+	until time.Time
+}
+
+func (e *throttleError) Error() string {
+	return fmt.Sprintf("%s is throttling requests, retry after %s", e.host, e.until.UTC().Format(time.RFC3339))
+}
+
+// throttlingTransport pauses every request to a host that answered 429 Too Many Requests or
+// a maxlag error until its Retry-After has passed. Requests made in the meantime fail with a
+// throttleError without being sent, and so do the throttled responses themselves.
+type throttlingTransport struct {
+	next http.RoundTripper
+
+	mu     sync.Mutex
+	paused map[string]time.Time
+}
+
+func (t *throttlingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Hostname()
+
+	t.mu.Lock()
+	until, found := t.paused[host]
+	if found && time.Now().After(until) {
+		delete(t.paused, host)
+		found = false
+	}
+	t.mu.Unlock()
+	if found {
+		return nil, &throttleError{host: host, until: until}
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	pause := defaultThrottlePause
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+	case resp.Header.Get("MediaWiki-API-Error") == "maxlag":
+		pause = 5 * time.Second
+	default:
+		return resp, nil
+	}
+	resp.Body.Close()
+
+	if after, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+		pause = after
+	}
+	until = time.Now().Add(pause)
+
+	t.mu.Lock()
+	t.paused[host] = until
+	t.mu.Unlock()
+
+	return nil, &throttleError{host: host, until: until}
+}
+
+// parseRetryAfter reads a Retry-After header, given either in seconds or as an HTTP date.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(at.Sub(now), 0), true
+	}
+
+	return 0, false
+}
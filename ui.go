@@ -0,0 +1,16 @@
+package main
+
+import (
+	"embed"
+	"net/http"
+)
+
+//go:embed ui/*.html
+var uiFiles embed.FS
+
+// uiPage serves one of the embedded demo pages.
+func uiPage(name string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFileFS(w, r, uiFiles, "ui/"+name)
+	}
+}
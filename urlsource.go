@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// urlSource picks words from the one page a pick names with `url`, on a self-hosted MediaWiki
+// or any other allowed host. The page is downloaded once however often the pick asks for it.
+type urlSource struct {
+	address string
+
+	mu      sync.Mutex
+	article *Article
+}
+
+// Supports reports whether the language is known: it only decides how the page's words are
+// split and lowercased, but is stored with them and used for lookups on other wikis.
+func (s *urlSource) Supports(language string) bool {
+	return knownLanguage(language)
+}
+
+func (s *urlSource) Fetch(ctx context.Context, language string) (*Article, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.article == nil {
+		article, err := fetchArticleAt(ctx, s.address)
+		if err != nil {
+			return nil, err
+		}
+		s.article = article
+	}
+
+	return s.article, nil
+}
+
+// urlClient fetches pages by address like upstreamClient, through the same transport, but
+// checks every redirect with checkURL too, so that an open redirect on an allowed wiki can't
+// lead to other hosts.
+var urlClient = &http.Client{
+	Transport: upstreamClient.Transport,
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if err := upstreamClient.CheckRedirect(req, via); err != nil {
+			return err
+		}
+		_, err := checkURL(req.URL.String())
+		return err
+	},
+}
+
+// checkURL parses a `url` parameter, which must be an http or https address on one of the
+// configured editions or url_domains, so that picks can't be used to reach other hosts.
+func checkURL(address string) (*url.URL, error) {
+	u, err := url.Parse(address)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || !allowedURLHost(strings.ToLower(u.Hostname())) {
+		err := invalidParameter("url", "url must be a page on one of the configured wikis")
+		err.Details["url"] = address
+		return nil, err
+	}
+
+	return u, nil
+}
+
+// allowedURLHost reports whether pages on the host may be fetched by address.
+func allowedURLHost(host string) bool {
+	if host == "" {
+		return false
+	}
+	if knownWikiHost(host) {
+		return true
+	}
+
+	configMu.RLock()
+	defer configMu.RUnlock()
+	for _, domain := range urlDomains {
+		if parent, found := strings.CutPrefix(domain, "*."); found {
+			if strings.HasSuffix(host, "."+parent) {
+				return true
+			}
+		} else if host == domain {
+			return true
+		}
+	}
+
+	return false
+}
+
+// fetchArticleAt downloads and extracts the page at the address, which must pass checkURL.
+func fetchArticleAt(ctx context.Context, address string) (*Article, error) {
+	u, err := checkURL(address)
+	if err != nil {
+		return nil, err
+	}
+
+	release, err := acquireFetchSlot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := urlClient.Do(req)
+	var apiErr *apiError
+	if errors.As(err, &apiErr) {
+		// A redirect to a host that isn't allowed.
+		return nil, apiErr
+	}
+	if err != nil {
+		return nil, upstreamError(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, upstreamError(fmt.Errorf("%s returned %s", u.Hostname(), resp.Status))
+	}
+
+	article, err := ExtractArticle(resp.Body)
+	if err != nil {
+		return nil, upstreamError(err)
+	}
+	article.URL = resp.Request.URL.String()
+
+	return article, nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// WarningCode identifies a condition that didn't stop a pick but that the client may want to
+// know about.
+type WarningCode string
+
+const (
+	// WarningFallbackLanguage: the words are in a fallback language, not the requested one.
+	WarningFallbackLanguage WarningCode = "FALLBACK_LANGUAGE"
+	// WarningMostWordsFiltered: the filters removed most of the words of the articles.
+	WarningMostWordsFiltered WarningCode = "MOST_WORDS_FILTERED"
+	// WarningStubArticle: a source article is so short it is probably a stub.
+	WarningStubArticle WarningCode = "STUB_ARTICLE"
+	// WarningFewerWords: fewer words than `count` were left to pick.
+	WarningFewerWords WarningCode = "FEWER_WORDS"
+)
+
+// Warning is a non-fatal condition reported with a pick.
+type Warning struct {
+	Code    WarningCode `json:"code"`
+	Message string      `json:"message"`
+}
+
+// stubArticleWords is the fewest words an article needs not to be reported as a stub, and
+// mostFilteredShare the share of words the filters must remove to be reported.
+const (
+	stubArticleWords  = 150
+	mostFilteredShare = 0.9
+)
+
+// warnings lists the conditions worth reporting about a pick of the words from the pool.
+// Summaries are short by design, so lite picks aren't warned about stubs.
+func (p *candidatePool) warnings(words []string) []Warning {
+	var warnings []Warning
+	if p.Language != p.opts.Language {
+		warnings = append(warnings, Warning{
+			Code:    WarningFallbackLanguage,
+			Message: fmt.Sprintf("no words could be picked in %s, so they are in %s", p.opts.Language, p.Language),
+		})
+	}
+
+	if len(p.extracted) > 0 {
+		removed := float64(len(p.extracted)-len(p.Words)) / float64(len(p.extracted))
+		if removed >= mostFilteredShare {
+			warnings = append(warnings, Warning{
+				Code:    WarningMostWordsFiltered,
+				Message: fmt.Sprintf("the filters removed %d%% of the words in the articles", int(math.Round(removed*100))),
+			})
+		}
+	}
+
+	if !p.opts.Lite {
+		// A source like a small dump may return the same article more than once.
+		seen := make(map[Source]bool)
+		for _, article := range p.Articles {
+			source := Source{Title: article.Title, URL: article.URL}
+			if seen[source] {
+				continue
+			}
+			seen[source] = true
+			if n := len(WordsFromParagraphs(article.Paragraphs, p.Language)); n < stubArticleWords {
+				warnings = append(warnings, Warning{
+					Code:    WarningStubArticle,
+					Message: fmt.Sprintf("%q has only %d words", article.Title, n),
+				})
+			}
+		}
+	}
+
+	if len(words) < p.opts.Count {
+		warnings = append(warnings, Warning{
+			Code:    WarningFewerWords,
+			Message: fmt.Sprintf("only %d of the %d words requested were left to pick", len(words), p.opts.Count),
+		})
+	}
+
+	return warnings
+}
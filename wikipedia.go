@@ -0,0 +1,314 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	xlanguage "golang.org/x/text/language"
+)
+
+// maxRedirects is how many redirects a request to Wikipedia or another upstream may follow.
+var maxRedirects = 5
+
+// upstreamClient sends every request to Wikipedia, Wiktionary and Wikimedia, backing off
+// from hosts that throttle it.
+var upstreamClient = &http.Client{
+	Transport: &throttlingTransport{next: http.DefaultTransport, paused: make(map[string]time.Time)},
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if len(via) > maxRedirects {
+			return fmt.Errorf("stopped after %d redirects", maxRedirects)
+		}
+		return nil
+	},
+}
+
+// defaultRandomArticleURLs are the built-in Wikipedia editions, which the config file can
+// override or extend.
+var defaultRandomArticleURLs = map[string]string{
+	"en": "https://en.wikipedia.org/wiki/Special:Random",
+	"fr": "https://fr.wikipedia.org/wiki/Sp%C3%A9cial:Page_au_hasard",
+	"de": "https://de.wikipedia.org/wiki/Spezial:Zuf%C3%A4llige_Seite",
+}
+
+// randomArticleURLByLanguage are the editions in use, guarded by configMu.
+var randomArticleURLByLanguage = maps.Clone(defaultRandomArticleURLs)
+
+// randomArticleURL returns the address redirecting to a random article of the language's
+// edition.
+func randomArticleURL(language string) (string, bool) {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	address, found := randomArticleURLByLanguage[language]
+	return address, found
+}
+
+// wikimediaProjects are the Wikimedia projects a wikipedia pick can draw from with `project`.
+var wikimediaProjects = map[string]bool{
+	"wikipedia":  true,
+	"wikinews":   true,
+	"wikiquote":  true,
+	"wikibooks":  true,
+	"wikivoyage": true,
+}
+
+// wikimediaSource picks words from random pages of a Wikimedia project, Wikipedia unless a
+// pick sets `project`. With lite set it reads only page summaries.
+type wikimediaSource struct {
+	project string
+	lite    bool
+}
+
+func init() {
+	RegisterWordSource("wikipedia", wikimediaSource{project: "wikipedia"})
+}
+
+// Supports reports whether the language is one of the Wikipedia editions we pick from. The
+// sister projects have editions in each of them too.
+func (s wikimediaSource) Supports(language string) bool {
+	_, found := randomArticleURL(language)
+	return found
+}
+
+// knownLanguage reports whether the language is one of the configured editions or an ISO 639
+// code in canonical form. Languages become parts of upstream hostnames, such as those of
+// Wiktionary, so anything else is refused.
+func knownLanguage(language string) bool {
+	if _, found := randomArticleURL(language); found {
+		return true
+	}
+	base, err := xlanguage.ParseBase(language)
+	return err == nil && base.String() == language
+}
+
+// knownWikiHost reports whether the host serves one of the configured Wikipedia editions, or
+// a Wikimedia project in one of their languages.
+func knownWikiHost(host string) bool {
+	configMu.RLock()
+	defer configMu.RUnlock()
+
+	for language, address := range randomArticleURLByLanguage {
+		if u, err := url.Parse(address); err == nil && u.Hostname() == host {
+			return true
+		}
+		for project := range wikimediaProjects {
+			if host == language+"."+project+".org" {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// randomPageURL returns the address that redirects to a random page of the language's
+// edition of the project.
+func (s wikimediaSource) randomPageURL(language string) string {
+	if s.project == "wikipedia" {
+		address, _ := randomArticleURL(language)
+		return address
+	}
+
+	// The canonical name of the special page works in every language.
+	return fmt.Sprintf("https://%s.%s.org/wiki/Special:Random", language, s.project)
+}
+
+// useExtracts makes the Wikipedia source ask the API for plain text extracts instead of
+// downloading and parsing the article HTML. Editions without the TextExtracts extension
+// fall back to HTML. BenchmarkExtractPlainText splits an extract about six times faster than
+// BenchmarkExtractHTML parses the same article, which is also several times the download.
+var useExtracts = true
+
+// Fetch downloads a random article in the language and extracts its text.
+func (s wikimediaSource) Fetch(ctx context.Context, language string) (article *Article, err error) {
+	ctx, span := tracer.Start(ctx, "wikipedia.fetch", trace.WithAttributes(
+		attribute.String("language", language),
+		attribute.String("project", s.project),
+		attribute.Bool("extracts", useExtracts),
+		attribute.Bool("lite", s.lite),
+	))
+	defer func() { endSpan(span, err) }()
+
+	upstream := s.project + ":" + language
+	breaker := upstreamBreaker(upstream)
+	if err := breaker.allow(upstream, time.Now()); err != nil {
+		return nil, err
+	}
+	defer func() { breaker.record(ctx, err, time.Now()) }()
+
+	release, err := acquireFetchSlot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	if s.lite {
+		article, err := s.fetchSummary(ctx, language)
+		if err != nil || len(article.Paragraphs) > 0 {
+			return article, err
+		}
+	}
+	if useExtracts {
+		article, err := s.fetchExtract(ctx, language)
+		if err != nil || len(article.Paragraphs) > 0 {
+			return article, err
+		}
+	}
+
+	return s.fetchArticleHTML(ctx, language)
+}
+
+// fetchArticleHTML downloads the HTML of a random article and extracts its paragraphs.
+func (s wikimediaSource) fetchArticleHTML(ctx context.Context, language string) (*Article, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.randomPageURL(language), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := upstreamClient.Do(req)
+	if err != nil {
+		return nil, upstreamError(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, upstreamError(fmt.Errorf("%s returned %s", s.project, resp.Status))
+	}
+
+	_, span := tracer.Start(ctx, "extract.html")
+	article, err := ExtractArticle(resp.Body)
+	endSpan(span, err)
+	if err != nil {
+		return nil, upstreamError(err)
+	}
+	// The random page redirects to the article, so the request now holds its address.
+	article.URL = resp.Request.URL.String()
+
+	return article, nil
+}
+
+// apiURL returns the MediaWiki action API endpoint of the language's edition.
+func (s wikimediaSource) apiURL(language string) (string, error) {
+	configMu.RLock()
+	endpoint, found := apiURLByLanguage[language]
+	configMu.RUnlock()
+	if found && s.project == "wikipedia" {
+		return endpoint, nil
+	}
+
+	random, err := url.Parse(s.randomPageURL(language))
+	if err != nil {
+		return "", err
+	}
+
+	return random.Scheme + "://" + random.Host + "/w/api.php", nil
+}
+
+// extractResponse is the part of an action=query&prop=extracts response we read.
+type extractResponse struct {
+	Query struct {
+		Pages []struct {
+			Title    string `json:"title"`
+			FullURL  string `json:"fullurl"`
+			Extract  string `json:"extract"`
+			Original struct {
+				Source string `json:"source"`
+			} `json:"original"`
+			Categories []struct {
+				Title string `json:"title"`
+			} `json:"categories"`
+		} `json:"pages"`
+	} `json:"query"`
+}
+
+// fetchExtract asks the API for the plain text of a random article. The returned article has
+// no paragraphs if the edition doesn't provide extracts.
+func (s wikimediaSource) fetchExtract(ctx context.Context, language string) (*Article, error) {
+	endpoint, err := s.apiURL(language)
+	if err != nil {
+		return nil, err
+	}
+
+	query := url.Values{
+		"action":          {"query"},
+		"format":          {"json"},
+		"formatversion":   {"2"},
+		"generator":       {"random"},
+		"grnnamespace":    {"0"},
+		"grnlimit":        {"1"},
+		"prop":            {"extracts|info|pageimages|categories"},
+		"inprop":          {"url"},
+		"piprop":          {"original"},
+		"clshow":          {"!hidden"},
+		"cllimit":         {"max"},
+		"explaintext":     {"1"},
+		"exsectionformat": {"wiki"},
+		"maxlag":          {maxLag},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := upstreamClient.Do(req)
+	if err != nil {
+		return nil, upstreamError(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, upstreamError(fmt.Errorf("%s returned %s", s.project, resp.Status))
+	}
+
+	_, span := tracer.Start(ctx, "extract.plaintext")
+	defer span.End()
+
+	var body extractResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, upstreamError(fmt.Errorf("failed to decode extract: %w", err))
+	}
+
+	article := &Article{}
+	if len(body.Query.Pages) == 0 {
+		return article, nil
+	}
+
+	page := body.Query.Pages[0]
+	article.Title = page.Title
+	article.URL = page.FullURL
+	article.Image = page.Original.Source
+	for _, category := range page.Categories {
+		// Drop the localised "Category:" namespace prefix.
+		_, name, _ := strings.Cut(category.Title, ":")
+		article.Categories = append(article.Categories, name)
+	}
+	article.Paragraphs, article.Sections = extractParagraphs(page.Extract)
+
+	return article, nil
+}
+
+// extractParagraphs splits a plain text extract into paragraphs and the heading of the section
+// each is in. Extracts put each paragraph on its own line and mark section headings with "==".
+func extractParagraphs(extract string) (paragraphs, sections []string) {
+	section := ""
+	for _, line := range strings.Split(removeFormulas(cleanText(extract)), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "==") {
+			section = strings.TrimSpace(strings.Trim(line, "="))
+			continue
+		}
+		if line != "" {
+			paragraphs = append(paragraphs, line)
+			sections = append(sections, section)
+		}
+	}
+
+	return paragraphs, sections
+}
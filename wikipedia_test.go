@@ -0,0 +1,35 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+// The fixtures are the same article as rendered HTML, the way the HTML path downloads it, and
+// as the plain text extract the API returns for it.
+func readFixture(b *testing.B, name string) []byte {
+	data, err := os.ReadFile("testdata/" + name)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return data
+}
+
+func BenchmarkExtractHTML(b *testing.B) {
+	page := readFixture(b, "article.html")
+	b.SetBytes(int64(len(page)))
+	for b.Loop() {
+		if _, err := ExtractArticle(bytes.NewReader(page)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkExtractPlainText(b *testing.B) {
+	extract := string(readFixture(b, "article.txt"))
+	b.SetBytes(int64(len(extract)))
+	for b.Loop() {
+		extractParagraphs(extract)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Random Wikisource pages are often tables of contents, author pages or title pages, so up
+// to wikisourceAttempts pages are fetched looking for one with at least wikisourceMinWords
+// words of prose.
+const (
+	wikisourceAttempts = 5
+	wikisourceMinWords = 200
+)
+
+// proseMinWords is the fewest words a paragraph needs to count as prose rather than a
+// heading, navigation link or line of a page header.
+const proseMinWords = 8
+
+// wikisourceSource picks words from random texts on Wikisource, such as novel chapters and
+// essays, which have more natural prose vocabulary than encyclopedia articles.
+type wikisourceSource struct {
+	wikimediaSource
+}
+
+func init() {
+	RegisterWordSource("wikisource", wikisourceSource{wikimediaSource{project: "wikisource"}})
+}
+
+// Fetch returns the first random page with enough prose, or the one with the most prose if
+// none of the attempts has enough.
+func (s wikisourceSource) Fetch(ctx context.Context, language string) (article *Article, err error) {
+	ctx, span := tracer.Start(ctx, "wikisource.fetch", trace.WithAttributes(
+		attribute.String("language", language),
+	))
+	defer func() { endSpan(span, err) }()
+
+	upstream := "wikisource:" + language
+	breaker := upstreamBreaker(upstream)
+	if err := breaker.allow(upstream, time.Now()); err != nil {
+		return nil, err
+	}
+	defer func() { breaker.record(ctx, err, time.Now()) }()
+
+	release, err := acquireFetchSlot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	var best *Article
+	bestWords := -1
+	for attempt := 0; attempt < wikisourceAttempts; attempt++ {
+		page, err := s.fetchExtract(ctx, language)
+		if err == nil && len(page.Paragraphs) == 0 {
+			page, err = s.fetchArticleHTML(ctx, language)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		keepProse(page)
+		words := len(WordsFromParagraphs(page.Paragraphs, language))
+		if words >= wikisourceMinWords {
+			return page, nil
+		}
+		if words > bestWords {
+			best, bestWords = page, words
+		}
+	}
+
+	return best, nil
+}
+
+// keepProse drops the paragraphs of the article too short to be prose.
+func keepProse(article *Article) {
+	var prose, sections []string
+	for i, paragraph := range article.Paragraphs {
+		if len(strings.Fields(paragraph)) >= proseMinWords {
+			prose = append(prose, paragraph)
+			sections = append(sections, article.section(i))
+		}
+	}
+
+	article.Paragraphs, article.Sections = prose, sections
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// defaultWiktionaryDefinitionURL is the REST endpoint returning definitions of a word, grouped
+// by the language the word belongs to. The definitions themselves are in English.
+const defaultWiktionaryDefinitionURL = "https://en.wiktionary.org/api/rest_v1/page/definition/"
+
+// wiktionaryDefinitionURL is the definition endpoint in use, guarded by configMu.
+var wiktionaryDefinitionURL = defaultWiktionaryDefinitionURL
+
+var htmlTag = regexp.MustCompile(`<[^>]+>`)
+
+type wiktionaryUsage struct {
+	PartOfSpeech string `json:"partOfSpeech"`
+	Definitions  []struct {
+		Definition string `json:"definition"`
+	} `json:"definitions"`
+}
+
+// lookupDefinition returns the first English definition Wiktionary has for the word in the
+// language, or "" if it has none. Words are picked lowercase, so the capitalised form is
+// tried too, which matters for German nouns.
+func lookupDefinition(ctx context.Context, word string, language string) (string, error) {
+	variants := []string{word}
+	if first, size := utf8.DecodeRuneInString(word); unicode.IsLower(first) {
+		variants = append(variants, string(unicode.ToUpper(first))+word[size:])
+	}
+
+	for _, variant := range variants {
+		definition, err := fetchDefinition(ctx, variant, language)
+		if err != nil || definition != "" {
+			return definition, err
+		}
+	}
+
+	return "", nil
+}
+
+func fetchDefinition(ctx context.Context, word string, language string) (string, error) {
+	release, err := acquireFetchSlot(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	configMu.RLock()
+	endpoint := wiktionaryDefinitionURL
+	configMu.RUnlock()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+url.PathEscape(word), nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := upstreamClient.Do(req)
+	if err != nil {
+		return "", upstreamError(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return "", nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", upstreamError(fmt.Errorf("wiktionary returned %s", resp.Status))
+	}
+
+	var usages map[string][]wiktionaryUsage
+	if err := json.NewDecoder(resp.Body).Decode(&usages); err != nil {
+		return "", upstreamError(fmt.Errorf("failed to decode definition: %w", err))
+	}
+
+	for _, usage := range usages[language] {
+		for _, definition := range usage.Definitions {
+			text := strings.Join(strings.Fields(html.UnescapeString(htmlTag.ReplaceAllString(definition.Definition, ""))), " ")
+			if text != "" {
+				return text, nil
+			}
+		}
+	}
+
+	return "", nil
+}
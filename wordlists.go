@@ -0,0 +1,199 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// adminToken guards the /admin endpoints. They are disabled when it is empty.
+var adminToken string
+
+// Word list tables. Blocked words are never returned; when a language has any allowed
+// words, only those are returned. Each namespace has its own lists.
+const (
+	blocklistTable = "blocked_words"
+	allowlistTable = "allowed_words"
+)
+
+func initWordListTables() error {
+	for _, table := range []string{blocklistTable, allowlistTable} {
+		_, err := db.Exec(`CREATE TABLE IF NOT EXISTS ` + table + ` (namespace TEXT NOT NULL DEFAULT '',word TEXT,language TEXT,PRIMARY KEY(namespace, word, language))`)
+		if err != nil {
+			return err
+		}
+		if err := addNamespaceKey(table); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func getWordList(tx dbtx, table string, namespace string, language string) (map[string]struct{}, error) {
+	rows, err := tx.Query("SELECT word FROM "+table+" WHERE namespace=? AND language=?", namespace, language)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	words := make(map[string]struct{})
+	for rows.Next() {
+		var word string
+		if err := rows.Scan(&word); err != nil {
+			return nil, err
+		}
+		words[word] = struct{}{}
+	}
+	return words, rows.Err()
+}
+
+func addToWordList(tx dbtx, table string, namespace string, words []string, language string) error {
+	stmt, err := tx.Prepare("INSERT OR IGNORE INTO " + table + "(namespace,word,language) VALUES (?,?,?)")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, word := range words {
+		if _, err := stmt.Exec(namespace, word, language); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func removeFromWordList(tx dbtx, table string, namespace string, words []string, language string) error {
+	stmt, err := tx.Prepare("DELETE FROM " + table + " WHERE namespace=? AND word=? AND language=?")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, word := range words {
+		if _, err := stmt.Exec(namespace, word, language); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// wordLists holds a language's blocklist and allowlist.
+type wordLists struct {
+	blocked map[string]struct{}
+	allowed map[string]struct{}
+}
+
+func loadWordLists(namespace string, language string) (*wordLists, error) {
+	blocked, err := getWordList(db, blocklistTable, namespace, language)
+	if err != nil {
+		return nil, err
+	}
+	allowed, err := getWordList(db, allowlistTable, namespace, language)
+	if err != nil {
+		return nil, err
+	}
+
+	return &wordLists{blocked: blocked, allowed: allowed}, nil
+}
+
+// Apply removes blocked words and, if the language has an allowlist, every word not on it.
+// A phrase is kept only if each of its words passes.
+func (l *wordLists) Apply(words []string) []string {
+	if len(l.blocked) == 0 && len(l.allowed) == 0 {
+		return words
+	}
+
+	kept := make([]string, 0, len(words))
+	for _, phrase := range words {
+		if containsBlocked(phrase, l.blocked) {
+			continue
+		}
+		if len(l.allowed) > 0 && !allWordsIn(phrase, l.allowed) {
+			continue
+		}
+		kept = append(kept, phrase)
+	}
+
+	return kept
+}
+
+func allWordsIn(phrase string, set map[string]struct{}) bool {
+	for _, word := range strings.Fields(phrase) {
+		if _, found := set[word]; !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// WordListRequest is the body accepted when adding or removing words from a list.
+type WordListRequest struct {
+	Words []string `json:"words"`
+}
+
+// WordListResponse lists the words on a blocklist or allowlist.
+type WordListResponse struct {
+	Language string   `json:"language"`
+	Words    []string `json:"words"`
+}
+
+// wordListHandler serves GET (list), POST (add) and DELETE (remove) for one word list table.
+// It is registered behind requireAdmin.
+func wordListHandler(table string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		namespace := requestNamespace(r.Context())
+		language := r.URL.Query().Get("language")
+		if language == "" {
+			writeError(w, invalidParameter("language", "language is required"))
+			return
+		}
+
+		if r.Method != http.MethodGet {
+			var body WordListRequest
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				writeError(w, invalidParameter("body", "invalid JSON body: %v", err))
+				return
+			}
+
+			words := make([]string, 0, len(body.Words))
+			for _, word := range body.Words {
+				if word = strings.TrimSpace(RemovePunctuation(word, language)); word != "" {
+					words = append(words, word)
+				}
+			}
+
+			update := addToWordList
+			if r.Method == http.MethodDelete {
+				update = removeFromWordList
+			}
+			err := retryTx(r.Context(), func(tx *sql.Tx) error {
+				return update(tx, table, namespace, words, language)
+			})
+			if err != nil {
+				writeError(w, err)
+				return
+			}
+		}
+
+		list, err := getWordList(db, table, namespace, language)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		response := WordListResponse{Language: language, Words: make([]string, 0, len(list))}
+		for word := range list {
+			response.Words = append(response.Words, word)
+		}
+		sort.Strings(response.Words)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}
+}
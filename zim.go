@@ -0,0 +1,339 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// zimMagic is the magic number at the start of every ZIM file.
+const zimMagic = 72173914
+
+// zimRandomAttempts is how many random directory entries are tried before giving up on
+// finding an article, since archives also hold images, redirects and metadata.
+const zimRandomAttempts = 100
+
+// zimSource picks words from random articles in local ZIM archives, such as the Wikipedia
+// snapshots distributed for Kiwix. Archives are added per language with -zim.
+type zimSource struct {
+	mu       sync.RWMutex
+	archives map[string]*zimArchive
+}
+
+var zimArchives = &zimSource{archives: make(map[string]*zimArchive)}
+
+func init() {
+	RegisterWordSource("zim", zimArchives)
+}
+
+// zimFlag collects repeated -zim language=path flags.
+type zimFlag struct{}
+
+func (zimFlag) String() string {
+	return ""
+}
+
+func (zimFlag) Set(value string) error {
+	language, path, found := strings.Cut(value, "=")
+	if !found || language == "" || path == "" {
+		return fmt.Errorf("expected language=path, got %q", value)
+	}
+
+	archive, err := openZIM(path)
+	if err != nil {
+		return err
+	}
+
+	zimArchives.mu.Lock()
+	defer zimArchives.mu.Unlock()
+	zimArchives.archives[language] = archive
+	return nil
+}
+
+func (s *zimSource) archive(language string) *zimArchive {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.archives[language]
+}
+
+func (s *zimSource) Supports(language string) bool {
+	return s.archive(language) != nil
+}
+
+// Fetch returns a random article from the language's archive.
+func (s *zimSource) Fetch(ctx context.Context, language string) (*Article, error) {
+	archive := s.archive(language)
+	if archive == nil {
+		return nil, fmt.Errorf("no ZIM archive for language: %s", language)
+	}
+
+	for attempt := 0; attempt < zimRandomAttempts; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		entry, err := archive.entry(uint32(rand.Int63n(int64(archive.header.EntryCount))))
+		if err != nil {
+			return nil, err
+		}
+		if !entry.isArticle(archive) {
+			continue
+		}
+
+		blob, err := archive.blob(entry.Cluster, entry.Blob)
+		if err != nil {
+			return nil, err
+		}
+		article, err := ExtractArticle(bytes.NewReader(blob))
+		if err != nil {
+			return nil, err
+		}
+		if article.Title == "" {
+			article.Title = entry.Title
+		}
+		article.URL = entry.URL
+		return article, nil
+	}
+
+	return nil, fmt.Errorf("no article found in ZIM archive after %d attempts", zimRandomAttempts)
+}
+
+// zimHeader is the fixed-size header at the start of a ZIM file.
+type zimHeader struct {
+	Magic         uint32
+	MajorVersion  uint16
+	MinorVersion  uint16
+	UUID          [16]byte
+	EntryCount    uint32
+	ClusterCount  uint32
+	URLPtrPos     uint64
+	TitlePtrPos   uint64
+	ClusterPtrPos uint64
+	MimeListPos   uint64
+	MainPage      uint32
+	LayoutPage    uint32
+	ChecksumPos   uint64
+}
+
+// zimArchive reads directory entries and blobs from an open ZIM file.
+type zimArchive struct {
+	file   *os.File
+	header zimHeader
+	mimes  []string
+}
+
+// zimEntry is a directory entry pointing at a blob within a cluster.
+type zimEntry struct {
+	Mime      uint16
+	Namespace byte
+	Cluster   uint32
+	Blob      uint32
+	URL       string
+	Title     string
+}
+
+// Mime types at or above zimRedirectMime mark redirects, link targets and deleted entries,
+// which have no blob.
+const zimRedirectMime = 0xfffd
+
+// isArticle reports whether the entry is an HTML page in the article namespace
+// ('A' in older archives, 'C' in newer ones).
+func (e *zimEntry) isArticle(archive *zimArchive) bool {
+	if e.Mime >= zimRedirectMime || int(e.Mime) >= len(archive.mimes) {
+		return false
+	}
+	if e.Namespace != 'A' && e.Namespace != 'C' {
+		return false
+	}
+
+	return strings.HasPrefix(archive.mimes[e.Mime], "text/html")
+}
+
+func openZIM(path string) (*zimArchive, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	archive := &zimArchive{file: file}
+	if err := binary.Read(io.NewSectionReader(file, 0, 80), binary.LittleEndian, &archive.header); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to read ZIM header: %w", err)
+	}
+	if archive.header.Magic != zimMagic {
+		file.Close()
+		return nil, fmt.Errorf("%s is not a ZIM file", path)
+	}
+	if archive.header.EntryCount == 0 {
+		file.Close()
+		return nil, fmt.Errorf("%s has no entries", path)
+	}
+
+	mimes := bufio.NewReader(io.NewSectionReader(file, int64(archive.header.MimeListPos), 1<<20))
+	for {
+		mime, err := mimes.ReadString(0)
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to read ZIM MIME list: %w", err)
+		}
+		if mime = strings.TrimSuffix(mime, "\x00"); mime == "" {
+			break
+		}
+		archive.mimes = append(archive.mimes, mime)
+	}
+
+	return archive, nil
+}
+
+func (a *zimArchive) readUint64(offset int64) (uint64, error) {
+	var buf [8]byte
+	if _, err := a.file.ReadAt(buf[:], offset); err != nil {
+		return 0, err
+	}
+
+	return binary.LittleEndian.Uint64(buf[:]), nil
+}
+
+// entry reads the directory entry at the index of the URL pointer list.
+func (a *zimArchive) entry(index uint32) (*zimEntry, error) {
+	offset, err := a.readUint64(int64(a.header.URLPtrPos) + 8*int64(index))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ZIM entry pointer: %w", err)
+	}
+
+	r := bufio.NewReader(io.NewSectionReader(a.file, int64(offset), 1<<16))
+	var fixed struct {
+		Mime         uint16
+		ParameterLen uint8
+		Namespace    byte
+		Revision     uint32
+	}
+	if err := binary.Read(r, binary.LittleEndian, &fixed); err != nil {
+		return nil, fmt.Errorf("failed to read ZIM entry: %w", err)
+	}
+
+	entry := &zimEntry{Mime: fixed.Mime, Namespace: fixed.Namespace}
+	if entry.Mime >= zimRedirectMime {
+		return entry, nil
+	}
+
+	var location [2]uint32
+	if err := binary.Read(r, binary.LittleEndian, &location); err != nil {
+		return nil, fmt.Errorf("failed to read ZIM entry: %w", err)
+	}
+	entry.Cluster, entry.Blob = location[0], location[1]
+
+	url, err := r.ReadString(0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ZIM entry URL: %w", err)
+	}
+	title, err := r.ReadString(0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ZIM entry title: %w", err)
+	}
+	entry.URL = strings.TrimSuffix(url, "\x00")
+	entry.Title = strings.TrimSuffix(title, "\x00")
+	if entry.Title == "" {
+		entry.Title = entry.URL
+	}
+
+	return entry, nil
+}
+
+// blob returns the content of a blob, decompressing its cluster.
+func (a *zimArchive) blob(cluster uint32, blob uint32) ([]byte, error) {
+	if cluster >= a.header.ClusterCount {
+		return nil, fmt.Errorf("ZIM cluster %d out of range", cluster)
+	}
+
+	start, err := a.readUint64(int64(a.header.ClusterPtrPos) + 8*int64(cluster))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ZIM cluster pointer: %w", err)
+	}
+	end := a.header.ChecksumPos
+	if cluster+1 < a.header.ClusterCount {
+		if end, err = a.readUint64(int64(a.header.ClusterPtrPos) + 8*int64(cluster+1)); err != nil {
+			return nil, fmt.Errorf("failed to read ZIM cluster pointer: %w", err)
+		}
+	}
+	if end <= start {
+		return nil, fmt.Errorf("ZIM cluster %d is empty", cluster)
+	}
+
+	section := io.NewSectionReader(a.file, int64(start), int64(end-start))
+	var info [1]byte
+	if _, err := section.Read(info[:]); err != nil {
+		return nil, fmt.Errorf("failed to read ZIM cluster: %w", err)
+	}
+
+	var data []byte
+	switch info[0] & 0x0f {
+	case 0, 1:
+		data, err = io.ReadAll(section)
+	case 4:
+		var r *xz.Reader
+		if r, err = xz.NewReader(section); err == nil {
+			data, err = io.ReadAll(r)
+		}
+	case 5:
+		var decoder *zstd.Decoder
+		if decoder, err = zstd.NewReader(section); err == nil {
+			data, err = io.ReadAll(decoder)
+			decoder.Close()
+		}
+	default:
+		err = fmt.Errorf("unsupported compression %d", info[0]&0x0f)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress ZIM cluster %d: %w", cluster, err)
+	}
+
+	// Blob offsets are 4 bytes, or 8 in extended clusters. The first offset points just past
+	// the offset list, so it also tells how many blobs the cluster holds.
+	offsetSize := 4
+	if info[0]&0x10 != 0 {
+		offsetSize = 8
+	}
+	offsetAt := func(i int) (uint64, error) {
+		position := i * offsetSize
+		if position+offsetSize > len(data) {
+			return 0, errors.New("blob offset out of range")
+		}
+		if offsetSize == 8 {
+			return binary.LittleEndian.Uint64(data[position:]), nil
+		}
+		return uint64(binary.LittleEndian.Uint32(data[position:])), nil
+	}
+
+	first, err := offsetAt(0)
+	if err != nil {
+		return nil, err
+	}
+	if int(blob) >= int(first)/offsetSize-1 {
+		return nil, fmt.Errorf("ZIM blob %d out of range in cluster %d", blob, cluster)
+	}
+	from, err := offsetAt(int(blob))
+	if err != nil {
+		return nil, err
+	}
+	to, err := offsetAt(int(blob) + 1)
+	if err != nil {
+		return nil, err
+	}
+	if from > to || to > uint64(len(data)) {
+		return nil, fmt.Errorf("ZIM blob %d in cluster %d is corrupt", blob, cluster)
+	}
+
+	return data[from:to], nil
+}